@@ -1,28 +1,37 @@
+import contextlib
 import os
 import platform
 import shutil
 import subprocess
+import sys
 
 lib_filename = 'libterraform.dll' if platform.system() == 'Windows' else 'libterraform.so'
 header_filename = 'libterraform.h'
 tf_filename = 'libterraform.go'
+tf_test_filename = 'libterraform_test.go'
 root = os.path.dirname(os.path.abspath(__file__))
 terraform_dirname = os.path.join(root, 'terraform')
-tf_path = os.path.join(root, tf_filename)
 tf_package_name = 'github.com/hashicorp/terraform'
 plugin_patch_filename = 'plugin_patch.go'
 plugin_dirname = os.path.join(root, 'go-plugin')
 plugin_patch_path = os.path.join(root, plugin_patch_filename)
 plugin_package_name = 'github.com/hashicorp/go-plugin'
+# Patches of terraform packages, by file name, exporting their internals
+terraform_patches = {
+    'cliconfig_patch.go': os.path.join(terraform_dirname, 'internal', 'command', 'cliconfig'),
+    'command_patch.go': os.path.join(terraform_dirname, 'internal', 'command'),
+}
 
 
 class BuildError(Exception):
     pass
 
 
-def build(setup_kwargs):
+@contextlib.contextmanager
+def _patched_terraform(*filenames):
     """
-    This function is mandatory in order to build the extensions.
+    Patch go-plugin and terraform, with libterraform and the given files copied
+    into the terraform package, and recover them on exit.
     """
     if not os.path.exists(os.path.join(terraform_dirname, '.git')):
         raise BuildError(f'The directory {terraform_dirname} not exists or init. '
@@ -32,7 +41,8 @@ def build(setup_kwargs):
                          f'Please execute `git submodule init && git submodule update` to init it.')
 
     target_plugin_patch_path = os.path.join(plugin_dirname, plugin_patch_filename)
-    target_tf_path = os.path.join(terraform_dirname, tf_filename)
+    target_terraform_patch_paths = [os.path.join(dirname, filename) for filename, dirname in terraform_patches.items()]
+    target_tf_paths = [os.path.join(terraform_dirname, filename) for filename in (tf_filename,) + filenames]
     target_tf_mod_path = os.path.join(terraform_dirname, 'go.mod')
     lib_path = os.path.join(terraform_dirname, lib_filename)
     header_path = os.path.join(terraform_dirname, header_filename)
@@ -47,26 +57,54 @@ def build(setup_kwargs):
                                f'replace github.com/hashicorp/go-plugin v1.4.3 => ../go-plugin'
         f.write(modified_mod_content)
 
-    # Build libterraform
-    shutil.copyfile(tf_path, target_tf_path)
+    # Patch terraform
+    print('      - Patching terraform packages')
+    for filename, target_path in zip(terraform_patches, target_terraform_patch_paths):
+        shutil.copyfile(os.path.join(root, filename), target_path)
+
     try:
-        print('      - Building libterraform')
-        subprocess.check_call(
-            ['go', 'build', '-buildmode=c-shared', f'-o={lib_filename}', tf_package_name],
-            cwd=terraform_dirname
-        )
-        shutil.move(lib_path, os.path.join(root, 'libterraform', lib_filename))
+        for filename, target_tf_path in zip((tf_filename,) + filenames, target_tf_paths):
+            shutil.copyfile(os.path.join(root, filename), target_tf_path)
+        yield
     finally:
         # Remove external files
-        for path in (target_plugin_patch_path, target_tf_path, header_path, lib_path):
+        for path in (target_plugin_patch_path, *target_terraform_patch_paths, *target_tf_paths, header_path, lib_path):
             if os.path.exists(path):
                 os.remove(path)
         # Recover go.mod
         with open(target_tf_mod_path, 'w') as f:
             f.write(mod_content)
 
+
+def build(setup_kwargs):
+    """
+    This function is mandatory in order to build the extensions.
+    """
+    with _patched_terraform():
+        print('      - Building libterraform')
+        subprocess.check_call(
+            ['go', 'build', '-buildmode=c-shared', f'-o={lib_filename}', tf_package_name],
+            cwd=terraform_dirname
+        )
+        shutil.move(os.path.join(terraform_dirname, lib_filename), os.path.join(root, 'libterraform', lib_filename))
+
     return setup_kwargs
 
 
+def test():
+    """
+    Run the Go tests of libterraform, which are named TestLibterraform*.
+    """
+    with _patched_terraform(tf_test_filename):
+        print('      - Testing libterraform')
+        subprocess.check_call(
+            ['go', 'test', '-run', '^TestLibterraform', tf_package_name],
+            cwd=terraform_dirname
+        )
+
+
 if __name__ == '__main__':
-    build({})
+    if sys.argv[1:] == ['test']:
+        test()
+    else:
+        build({})
@@ -0,0 +1,42 @@
+package cliconfig
+
+import (
+	"log"
+	"os"
+
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// LoadConfigWithFile is like LoadConfig, except that the main CLI config is
+// read from the given file instead of the one chosen by TF_CLI_CONFIG_FILE or
+// the default one. It never touches the environment of the process.
+func LoadConfigWithFile(mainFilename string) (*Config, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	configVal := BuiltinConfig // copy
+	config := &configVal
+
+	if _, err := os.Stat(mainFilename); err == nil {
+		mainConfig, mainDiags := loadConfigFile(mainFilename)
+		diags = diags.Append(mainDiags)
+		config = config.Merge(mainConfig)
+	}
+
+	if configDir, err := ConfigDir(); err == nil {
+		if info, err := os.Stat(configDir); err == nil && info.IsDir() {
+			dirConfig, dirDiags := loadConfigDir(configDir)
+			diags = diags.Append(dirDiags)
+			config = config.Merge(dirConfig)
+		}
+	} else {
+		log.Printf("[DEBUG] couldn't find config directory: %s", err)
+	}
+
+	if envConfig := EnvConfig(); envConfig != nil {
+		// envConfig takes precedence
+		config = envConfig.Merge(config)
+	}
+
+	diags = diags.Append(config.Validate())
+
+	return config, diags
+}
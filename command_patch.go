@@ -0,0 +1,8 @@
+package command
+
+// FormatSourceCode formats the given source of a configuration or variables
+// file the same way as "terraform fmt". The source must be valid native
+// syntax, otherwise it is returned as it is.
+func FormatSourceCode(src []byte, filename string) []byte {
+	return (&FmtCommand{}).formatSourceCode(src, filename)
+}
@@ -1,34 +1,80 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
+	goversion "github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	hcljson "github.com/hashicorp/hcl/v2/json"
 	svchost "github.com/hashicorp/terraform-svchost"
 	"github.com/hashicorp/terraform-svchost/disco"
 	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/backend"
 	backendInit "github.com/hashicorp/terraform/internal/backend/init"
+	terraformProvider "github.com/hashicorp/terraform/internal/builtin/providers/terraform"
+	fileprovisioner "github.com/hashicorp/terraform/internal/builtin/provisioners/file"
+	localexec "github.com/hashicorp/terraform/internal/builtin/provisioners/local-exec"
+	remoteexec "github.com/hashicorp/terraform/internal/builtin/provisioners/remote-exec"
 	"github.com/hashicorp/terraform/internal/command"
 	"github.com/hashicorp/terraform/internal/command/cliconfig"
 	"github.com/hashicorp/terraform/internal/command/format"
+	"github.com/hashicorp/terraform/internal/command/jsonplan"
+	"github.com/hashicorp/terraform/internal/command/jsonprovider"
+	"github.com/hashicorp/terraform/internal/command/jsonstate"
 	"github.com/hashicorp/terraform/internal/command/views"
 	"github.com/hashicorp/terraform/internal/command/webbrowser"
 	"github.com/hashicorp/terraform/internal/configs"
+	"github.com/hashicorp/terraform/internal/configs/configload"
+	"github.com/hashicorp/terraform/internal/configs/configschema"
+	"github.com/hashicorp/terraform/internal/depsfile"
 	"github.com/hashicorp/terraform/internal/didyoumean"
 	"github.com/hashicorp/terraform/internal/experiments"
 	"github.com/hashicorp/terraform/internal/getproviders"
 	"github.com/hashicorp/terraform/internal/httpclient"
+	"github.com/hashicorp/terraform/internal/instances"
+	"github.com/hashicorp/terraform/internal/lang"
 	"github.com/hashicorp/terraform/internal/logging"
+	"github.com/hashicorp/terraform/internal/plans"
+	"github.com/hashicorp/terraform/internal/plans/planfile"
+	tfplugin "github.com/hashicorp/terraform/internal/plugin"
+	tfplugin6 "github.com/hashicorp/terraform/internal/plugin6"
+	"github.com/hashicorp/terraform/internal/providercache"
+	"github.com/hashicorp/terraform/internal/providers"
+	"github.com/hashicorp/terraform/internal/provisioners"
+	"github.com/hashicorp/terraform/internal/refactoring"
+	"github.com/hashicorp/terraform/internal/registry"
+	"github.com/hashicorp/terraform/internal/registry/regsrc"
+	"github.com/hashicorp/terraform/internal/states/statefile"
+	"github.com/hashicorp/terraform/internal/states/statemgr"
 	"github.com/hashicorp/terraform/internal/terminal"
+	"github.com/hashicorp/terraform/internal/terraform"
+	"github.com/hashicorp/terraform/internal/tfdiags"
 	"github.com/hashicorp/terraform/version"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/colorstring"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"io"
 	"log"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -42,10 +88,51 @@ import "C"
 // **********************************************
 
 var shutdownChs = make(map[chan struct{}]struct{})
+var shutdownChsLock sync.Mutex
 var logFile *os.File
+var origStdin = os.Stdin
 var origStdout = os.Stdout
 var origStderr = os.Stderr
 
+// cwdLock guards the working dir of the process, which is global. The runs
+// and exports switching it hold it exclusively until they restore it, while
+// the ones resolving relative paths against it hold it shared, see rlockCwd.
+var cwdLock sync.RWMutex
+
+// rlockCwd keeps the working dir of the process from being switched until
+// the returned function is called, for a run or export resolving relative
+// paths against it. It must not be taken again, nor cwdLock, until released.
+func rlockCwd() func() {
+	cwdLock.RLock()
+	return cwdLock.RUnlock
+}
+
+// timeoutExitCode is the exit code of a run shut down by its timeout, the
+// same as the one of the timeout command.
+const timeoutExitCode = 124
+
+// panicExitCode is the exit code of a run which panicked, the same as the one
+// of a crashed Terraform process.
+const panicExitCode = 11
+
+// panicOutput precedes the panic value and stack trace written to the stderr
+// of a run which panicked.
+const panicOutput = `
+!!!!!!!!!!!!!!!!!!!!!!!!!!! TERRAFORM CRASH !!!!!!!!!!!!!!!!!!!!!!!!!!!!
+
+Terraform crashed! This is always indicative of a bug within Terraform.
+Please report the crash with Terraform[1] so that we can fix this.
+
+[1]: https://github.com/hashicorp/terraform/issues
+
+!!!!!!!!!!!!!!!!!!!!!!!!!!! TERRAFORM CRASH !!!!!!!!!!!!!!!!!!!!!!!!!!!!
+
+`
+
+// timeoutGracePeriod is how long a run shut down by its timeout is given to
+// exit gracefully before its provider plugins are killed.
+const timeoutGracePeriod = 10 * time.Second
+
 func init() {
 	signalCh := make(chan os.Signal, 4)
 	signal.Notify(signalCh, ignoreSignals...)
@@ -54,45 +141,284 @@ func init() {
 		for {
 			<-signalCh
 			log.Printf("[INFO] Received signal, shutting down")
+			shutdownChsLock.Lock()
 			for shutdownCh := range shutdownChs {
-				shutdownCh <- struct{}{}
+				// Some commands never read the channel, so don't block on it
+				// while holding the lock.
+				select {
+				case shutdownCh <- struct{}{}:
+				default:
+				}
 			}
+			shutdownChsLock.Unlock()
 			log.Printf("[INFO] Received signal, shut down success")
 		}
 	}()
 }
 
+// shutdown asks the run of the given shutdown channel to shut down, like an
+// interrupt signal does. It returns false if the run already finished.
+func shutdown(shutdownCh chan struct{}) bool {
+	shutdownChsLock.Lock()
+	defer shutdownChsLock.Unlock()
+	if _, running := shutdownChs[shutdownCh]; !running {
+		return false
+	}
+	select {
+	case shutdownCh <- struct{}{}:
+	default:
+		// The run already has pending shutdown requests.
+	}
+	return true
+}
+
+// stopRun asks the run of the given shutdown channel to shut down gracefully,
+// like an interrupt. If the run doesn't finish within the grace period, it is
+// shut down forcibly like on a second interrupt, which cancels the running
+// operation, and its provider plugins are killed, leaving the ones of other
+// runs alone.
+func stopRun(shutdownCh chan struct{}, done <-chan struct{}, clients *plugin.ClientScope, grace time.Duration) {
+	if !shutdown(shutdownCh) {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(grace):
+		log.Printf("[WARN] Run did not shut down within %s, killing plugins", grace)
+		shutdown(shutdownCh)
+		clients.Kill()
+	}
+}
+
+// runHandle is a run of the CLI started with a run ID, through which the run
+// can be cancelled.
+type runHandle struct {
+	shutdownCh chan struct{}
+	done       <-chan struct{}
+	clients    *plugin.ClientScope
+}
+
+// runs are the running runs with a run ID by their ID, guarded by
+// shutdownChsLock.
+var runs = make(map[string]*runHandle)
+
+// CancelRun cancels the running run with the given run ID, returning 0 if
+// there is no such run. The run is shut down gracefully first, like on an
+// interrupt, and if it is still running after the given grace period in
+// milliseconds, it is shut down forcibly like on a second interrupt and its
+// provider plugins are killed. CancelRun returns right away, without waiting
+// for the run to finish.
+//
+//export CancelRun
+func CancelRun(cRunID *C.char, cGraceMs C.int) C.int {
+	shutdownChsLock.Lock()
+	run, ok := runs[C.GoString(cRunID)]
+	shutdownChsLock.Unlock()
+	if !ok {
+		return 0
+	}
+	go stopRun(run.shutdownCh, run.done, run.clients, time.Duration(cGraceMs)*time.Millisecond)
+	return 1
+}
+
+// runOptions are the settings of a single run of the CLI.
+type runOptions struct {
+	// Timeout is how long the run may take before it is shut down, or zero
+	// for no timeout.
+	Timeout time.Duration
+	// Stdin is read by the run for any prompts, and is closed when the run
+	// finishes. If nil, the run reads no input at all, so that prompts fail
+	// right away instead of waiting on the stdin of the host process.
+	Stdin *os.File
+	// CliConfigFile is the CLI config file loaded in place of the one given
+	// by the TF_CLI_CONFIG_FILE environment variable or the default one.
+	CliConfigFile string
+	// LogFile receives the logs of the run at LogLevel, independently of
+	// TF_LOG, and is closed when the run finishes. If LogPath is set, the
+	// file at that path is appended to instead.
+	LogFile  *os.File
+	LogPath  string
+	LogLevel hclog.Level
+	// RunID identifies the run to cancel it with CancelRun, if set.
+	RunID string
+	// DataDir and PluginCacheDir override the data dir given by the
+	// TF_DATA_DIR environment variable and the plugin cache dir of the CLI
+	// config for the run, if set. Like those, relative paths are resolved
+	// against the working dir given by the -chdir option.
+	DataDir        string
+	PluginCacheDir string
+	// DisableCheckpoint skips the checkpoint call for upgrade and security
+	// bulletin information, as if the CHECKPOINT_DISABLE environment variable
+	// was set.
+	DisableCheckpoint bool
+}
+
+// runOptionsJSON is the JSON representation of runOptions accepted by
+// RunCliWithOptions.
+type runOptionsJSON struct {
+	TimeoutMs         int    `json:"timeout_ms"`
+	StdinFd           *int   `json:"stdin_fd"`
+	CliConfigFile     string `json:"cli_config_file"`
+	LogFd             *int   `json:"log_fd"`
+	LogPath           string `json:"log_path"`
+	LogLevel          string `json:"log_level"`
+	RunID             string `json:"run_id"`
+	DataDir           string `json:"data_dir"`
+	PluginCacheDir    string `json:"plugin_cache_dir"`
+	DisableCheckpoint bool   `json:"disable_checkpoint"`
+}
+
+// parseRunOptions parses the JSON representation of runOptions.
+func parseRunOptions(optionsJSON string) (runOptions, error) {
+	var opts runOptions
+	if optionsJSON == "" {
+		return opts, nil
+	}
+	var raw runOptionsJSON
+	if err := json.Unmarshal([]byte(optionsJSON), &raw); err != nil {
+		return opts, fmt.Errorf("invalid run options JSON: %s", err)
+	}
+	opts.Timeout = time.Duration(raw.TimeoutMs) * time.Millisecond
+	opts.CliConfigFile = raw.CliConfigFile
+	opts.LogPath = raw.LogPath
+	opts.RunID = raw.RunID
+	opts.DataDir = raw.DataDir
+	opts.PluginCacheDir = raw.PluginCacheDir
+	opts.DisableCheckpoint = raw.DisableCheckpoint
+	opts.LogLevel = hclog.Trace
+	if raw.LogLevel != "" {
+		opts.LogLevel = hclog.LevelFromString(raw.LogLevel)
+		if opts.LogLevel == hclog.NoLevel {
+			return opts, fmt.Errorf("invalid log level %q, must be one of TRACE, DEBUG, INFO, WARN or ERROR", raw.LogLevel)
+		}
+	}
+	if raw.LogFd != nil {
+		opts.LogFile = os.NewFile(uintptr(*raw.LogFd), "libterraform/pipe/log")
+	}
+	if raw.StdinFd != nil {
+		opts.Stdin = os.NewFile(uintptr(*raw.StdinFd), "libterraform/pipe/stdin")
+	}
+	return opts, nil
+}
+
 //export RunCli
 func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int {
-	defer logging.PanicHandler()
+	Stdout := os.NewFile(uintptr(cStdOutFd), "libterraform/pipe/stdout")
+	Stderr := os.NewFile(uintptr(cStdErrFd), "libterraform/pipe/stderr")
+	return C.int(runCli(goArgs(cArgc, cArgv), Stdout, Stderr, runOptions{}))
+}
+
+// RunCliWithTimeout is like RunCli, shutting down the run if it takes longer
+// than the given timeout in milliseconds, in which case the exit code is 124.
+//
+// The run is shut down gracefully first, like on an interrupt, and its
+// provider plugins are killed if it doesn't exit soon after. As with a killed
+// CLI, the state may have been partially written by then.
+//
+//export RunCliWithTimeout
+func RunCliWithTimeout(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int, cTimeoutMs C.int) C.int {
+	Stdout := os.NewFile(uintptr(cStdOutFd), "libterraform/pipe/stdout")
+	Stderr := os.NewFile(uintptr(cStdErrFd), "libterraform/pipe/stderr")
+	opts := runOptions{
+		Timeout: time.Duration(cTimeoutMs) * time.Millisecond,
+	}
+	return C.int(runCli(goArgs(cArgc, cArgv), Stdout, Stderr, opts))
+}
+
+// RunCliWithOptions is like RunCli, with the settings of the run given as a
+// JSON object:
+//
+//   - "timeout_ms" is the timeout of the run, as for RunCliWithTimeout.
+//   - "stdin_fd" is the fd the run reads any prompts from, which is closed
+//     when the run finishes. Without it, prompts fail right away.
+//   - "cli_config_file" is the CLI config file of the run, as if given by the
+//     TF_CLI_CONFIG_FILE environment variable.
+//   - "log_fd" or "log_path" is where the logs of the run are written, in
+//     addition to any TF_LOG output. The fd is closed when the run finishes
+//     while the file at the path is appended to. Terraform logs through a
+//     single logger for the whole process, so the logs of runs overlapping
+//     in time are written to the logs of each of them: the logs of a run
+//     are only its own when no other run is running meanwhile.
+//   - "log_level" is the level of those logs, which is TRACE by default.
+//   - "run_id" identifies the run to cancel it with CancelRun. It must be
+//     unique among the running runs.
+//   - "data_dir" is the data dir of the run in place of the one given by the
+//     TF_DATA_DIR environment variable, or .terraform by default.
+//   - "plugin_cache_dir" is the plugin cache dir of the run in place of the
+//     one of the CLI config.
+//   - "disable_checkpoint" skips the checkpoint call, which otherwise connects
+//     to checkpoint-api.hashicorp.com unless CHECKPOINT_DISABLE is set.
+//
+// Relative data and plugin cache dirs are resolved against the working dir
+// given by the -chdir option, if any, like TF_DATA_DIR is. Runs in the same
+// working dir but with different data dirs have their own providers, modules
+// and backend config.
+//
+//export RunCliWithOptions
+func RunCliWithOptions(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int, cOptionsJSON *C.char) C.int {
+	Stdout := os.NewFile(uintptr(cStdOutFd), "libterraform/pipe/stdout")
+	Stderr := os.NewFile(uintptr(cStdErrFd), "libterraform/pipe/stderr")
+	opts, err := parseRunOptions(C.GoString(cOptionsJSON))
+	if err != nil {
+		fmt.Fprintln(Stderr, err)
+		Stdout.Close()
+		Stderr.Close()
+		return 1
+	}
+	return C.int(runCli(goArgs(cArgc, cArgv), Stdout, Stderr, opts))
+}
+
+// recoverRunPanic recovers a panic of a run, writing its value and stack
+// trace to stderr and setting the exit code to panicExitCode. It must be
+// deferred directly by the run.
+func recoverRunPanic(stderr io.Writer, exitCode *int) {
+	if r := recover(); r != nil {
+		fmt.Fprintf(stderr, "%s%v\n\n%s", panicOutput, r, debug.Stack())
+		*exitCode = panicExitCode
+	}
+}
 
+// runCli runs the CLI with the given args, writing the output to the given
+// stdout and stderr, which are closed when the run finishes.
+//
+// Unlike logging.PanicHandler, which exits the process, a panic of the run is
+// recovered: its value and stack trace are written to stderr and the exit
+// code is panicExitCode. Panics in other goroutines of the run, such as those
+// of the graph walks, still crash the process.
+func runCli(cliArgs []string, Stdout *os.File, Stderr *os.File, opts runOptions) (exitCode int) {
 	var err error
 
-	// Convert C variables to Go variables
 	os.Args = os.Args[:0]
 	os.Args = append(os.Args, "Terraform")
-	argc := int(cArgc)
-	slice := unsafe.Slice(cArgv, argc)
-	for _, s := range slice {
-		arg := C.GoString(s)
-		os.Args = append(os.Args, arg)
-	}
+	os.Args = append(os.Args, cliArgs...)
 
-	// Override stdout and stdin by given std fd
+	Stdin := opts.Stdin
+	if Stdin == nil {
+		Stdin, err = os.Open(os.DevNull)
+		if err != nil {
+			fmt.Fprintf(Stderr, "Failed to open %s as stdin: %s\n", os.DevNull, err)
+			Stdout.Close()
+			Stderr.Close()
+			return 1
+		}
+	}
 
-	Stdout := os.NewFile(uintptr(cStdOutFd), "libterraform/pipe/stdout")
-	Stderr := os.NewFile(uintptr(cStdErrFd), "libterraform/pipe/stderr")
+	// Override stdin, stdout and stderr by given std fd. The prompts of the
+	// commands read os.Stdin directly rather than through the Ui.
+	os.Stdin = Stdin
 	os.Stdout = Stdout
 	os.Stderr = Stderr
 	Ui = &ui{&cli.BasicUi{
 		Writer:      Stdout,
 		ErrorWriter: Stderr,
-		Reader:      os.Stdin,
+		Reader:      Stdin,
 	}}
 
 	defer func() {
+		os.Stdin = origStdin
 		os.Stdout = origStdout
 		os.Stderr = origStderr
+		Stdin.Close()
 		Stdout.Close()
 		Stderr.Close()
 		if len(checkpointResult) > 0 {
@@ -100,6 +426,10 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 		}
 	}()
 
+	// Deferred after the above, so that the panic is written before stderr
+	// is closed.
+	defer recoverRunPanic(Stderr, &exitCode)
+
 	tmpLogPath := os.Getenv(envTmpLogPath)
 	if tmpLogPath != "" {
 		f, err := os.OpenFile(tmpLogPath, os.O_RDWR|os.O_APPEND, 0666)
@@ -113,6 +443,19 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 		}
 	}
 
+	runLogFile := opts.LogFile
+	if opts.LogPath != "" {
+		runLogFile, err = os.OpenFile(opts.LogPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			Ui.Error(fmt.Sprintf("Failed to open log file: %s", err))
+			return 1
+		}
+	}
+	if runLogFile != nil {
+		defer runLogFile.Close()
+		defer registerLogSink(runLogFile, opts.LogLevel)()
+	}
+
 	log.Printf(
 		"[INFO] Terraform version: %s %s",
 		Version, VersionPrerelease)
@@ -145,7 +488,7 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 	// path in the TERRAFORM_CONFIG_FILE environment variable (though probably
 	// ill-advised) will be resolved relative to the true working directory,
 	// not the overridden one.
-	config, diags := cliconfig.LoadConfig()
+	config, diags := loadCliConfig(opts.CliConfigFile)
 
 	if len(diags) > 0 {
 		// Since we haven't instantiated a command.Meta yet, we need to do
@@ -169,26 +512,7 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 		}
 	}
 
-	// Get any configured credentials from the config and initialize
-	// a service discovery object. The slightly awkward predeclaration of
-	// disco is required to allow us to pass untyped nil as the creds source
-	// when creating the source fails. Otherwise we pass a typed nil which
-	// breaks the nil checks in the disco object
-	var services *disco.Disco
-	credsSrc, err := credentialsSource(config)
-	if err == nil {
-		services = disco.NewWithCredentialsSource(credsSrc)
-	} else {
-		// Most commands don't actually need credentials, and most situations
-		// that would get us here would already have been reported by the config
-		// loading above, so we'll just log this one as an aid to debugging
-		// in the unlikely event that it _does_ arise.
-		log.Printf("[WARN] Cannot initialize remote host credentials manager: %s", err)
-		// passing (untyped) nil as the creds source is okay because the disco
-		// object checks that and just acts as though no credentials are present.
-		services = disco.NewWithCredentialsSource(nil)
-	}
-	services.SetUserAgent(httpclient.TerraformUserAgent(version.String()))
+	services := newServices(config)
 
 	providerSrc, diags := providerSource(config.ProviderInstallation, services)
 	if len(diags) > 0 {
@@ -224,13 +548,6 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 	binName := filepath.Base(os.Args[0])
 	args := os.Args[1:]
 
-	originalWd, err := os.Getwd()
-	if err != nil {
-		// It would be very strange to end up here
-		Ui.Error(fmt.Sprintf("Failed to determine current working directory: %s", err))
-		return 1
-	}
-
 	// The arguments can begin with a -chdir option to ask Terraform to switch
 	// to a different working directory for the rest of its work. If that
 	// option is present then extractChdirOption returns a trimmed args with that option removed.
@@ -239,12 +556,33 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 		Ui.Error(fmt.Sprintf("Invalid -chdir option: %s", err))
 		return 1
 	}
-	if overrideWd != "" {
+	// -chdir=. is what the exports running commands in the caller's working
+	// dir pass, which needs no switch.
+	switchWd := overrideWd != "" && overrideWd != "."
+	if switchWd {
+		// Unlike the CLI, the host process goes on after the run, so its
+		// working dir is restored when the run finishes. Until then, other
+		// runs and exports depending on it wait.
+		cwdLock.Lock()
+		defer cwdLock.Unlock()
+	} else {
+		defer rlockCwd()()
+	}
+
+	originalWd, err := os.Getwd()
+	if err != nil {
+		// It would be very strange to end up here
+		Ui.Error(fmt.Sprintf("Failed to determine current working directory: %s", err))
+		return 1
+	}
+
+	if switchWd {
 		err := os.Chdir(overrideWd)
 		if err != nil {
 			Ui.Error(fmt.Sprintf("Error handling -chdir option: %s", err))
 			return 1
 		}
+		defer os.Chdir(originalWd)
 	}
 
 	// Commands get to hold on to the original working directory here,
@@ -252,22 +590,73 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 	// they should primarily be working with the override working directory
 	// that we've now switched to above.
 
+	// Make sure we clean up the managed plugins started by this run at the
+	// end of it, while the ones of other runs keep running.
+	clients := plugin.OpenClientScope()
+	defer clients.Close()
+
 	shutdownCh := make(chan struct{}, 2)
+	shutdownChsLock.Lock()
 	shutdownChs[shutdownCh] = struct{}{}
+	shutdownChsLock.Unlock()
 	defer func() {
+		shutdownChsLock.Lock()
 		delete(shutdownChs, shutdownCh)
 		close(shutdownCh)
+		shutdownChsLock.Unlock()
 	}()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	if opts.RunID != "" {
+		shutdownChsLock.Lock()
+		_, exists := runs[opts.RunID]
+		if !exists {
+			runs[opts.RunID] = &runHandle{shutdownCh: shutdownCh, done: done, clients: clients}
+		}
+		shutdownChsLock.Unlock()
+		if exists {
+			Ui.Error(fmt.Sprintf("Another run with the ID %q is running.", opts.RunID))
+			return 1
+		}
+		defer func() {
+			shutdownChsLock.Lock()
+			delete(runs, opts.RunID)
+			shutdownChsLock.Unlock()
+		}()
+	}
+
+	var timedOut int32
+	if opts.Timeout > 0 {
+		timer := time.AfterFunc(opts.Timeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			log.Printf("[WARN] Run timed out after %s, shutting down", opts.Timeout)
+			stopRun(shutdownCh, done, clients, timeoutGracePeriod)
+		})
+		defer timer.Stop()
+	}
+
 	meta := NewMeta(originalWd, streams, config, services, providerSrc, providerDevOverrides, unmanagedProviders, shutdownCh)
+	if opts.DataDir != "" {
+		meta.WorkingDir = WorkingDir(originalWd, opts.DataDir)
+	}
+	if opts.PluginCacheDir != "" {
+		meta.PluginCacheDir = opts.PluginCacheDir
+	}
 	commands := NewCommands(meta)
 
 	// Run checkpoint
-	go runCheckpoint(config)
-
-	// Make sure we clean up any managed plugins at the end of this
-	defer func() {
-		plugin.CleanupAndRemoveClients()
-	}()
+	if opts.DisableCheckpoint {
+		// The version command still waits for a result, so give it the one
+		// of a disabled checkpoint, which is drained when the run finishes.
+		select {
+		case checkpointResult <- nil:
+		default:
+		}
+	} else {
+		go runCheckpoint(config)
+	}
 
 	// Build the CLI so far, we do this so we can query the subcommand.
 	cliRunner := &cli.CLI{
@@ -350,7 +739,11 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 		}
 	}
 
-	exitCode, err := cliRunner.Run()
+	exitCode, err = cliRunner.Run()
+	if atomic.LoadInt32(&timedOut) == 1 {
+		Ui.Error(fmt.Sprintf("Terraform was shut down after the timeout of %s.", opts.Timeout))
+		return timeoutExitCode
+	}
 	if err != nil {
 		Ui.Error(fmt.Sprintf("Error executing CLI: %s", err.Error()))
 		return 1
@@ -360,10 +753,268 @@ func RunCli(cArgc C.int, cArgv **C.char, cStdOutFd C.int, cStdErrFd C.int) C.int
 	// plugins crashing
 	if exitCode != 0 {
 		for _, panicLog := range logging.PluginPanics() {
-			Ui.Error(panicLog)
+			fmt.Fprintln(Stderr, panicLog)
+		}
+	}
+	return exitCode
+}
+
+// registerLogSink registers a sink writing the logs at the given level to the
+// given file, independently of TF_LOG, and returns a function deregistering
+// it. Logs of the provider plugins are not included. The sink is registered
+// on the logger of the process, so it also receives the logs of any other run
+// meanwhile.
+func registerLogSink(f *os.File, level hclog.Level) func() {
+	l, ok := logging.HCLogger().(hclog.InterceptLogger)
+	if !ok {
+		return func() {}
+	}
+	sink := hclog.NewSinkAdapter(&hclog.LoggerOptions{
+		Level:             level,
+		Output:            f,
+		IndependentLevels: true,
+	})
+	l.RegisterSink(sink)
+	return func() {
+		l.DeregisterSink(sink)
+	}
+}
+
+// loadCliConfig loads the CLI config the same way as cliconfig.LoadConfig,
+// except that the given file, if any, is loaded in place of the one given by
+// the TF_CLI_CONFIG_FILE environment variable or the default one.
+func loadCliConfig(path string) (*cliconfig.Config, tfdiags.Diagnostics) {
+	if path == "" {
+		return cliconfig.LoadConfig()
+	}
+
+	var diags tfdiags.Diagnostics
+	if _, err := os.Stat(path); err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Invalid CLI configuration file",
+			fmt.Sprintf("Could not read the CLI configuration file %s: %s.", path, err),
+		))
+	}
+
+	// LoadConfigWithFile is added by cliconfig_patch.go.
+	config, loadDiags := cliconfig.LoadConfigWithFile(path)
+	return config, diags.Append(loadDiags)
+}
+
+// runCommand runs the CLI with the given args, capturing the output of the
+// command. It is used by the exports built on top of the commands.
+func runCommand(args ...string) (exitCode int, stdout string, stderr string, err error) {
+	return runCommandWithOptions(runOptions{}, args...)
+}
+
+// runCommandWithOptions is like runCommand, running the CLI with the given
+// settings.
+func runCommandWithOptions(opts runOptions, args ...string) (exitCode int, stdout string, stderr string, err error) {
+	stdoutFile, err := os.CreateTemp("", "libterraform-stdout")
+	if err != nil {
+		return 1, "", "", err
+	}
+	defer os.Remove(stdoutFile.Name())
+	stderrFile, err := os.CreateTemp("", "libterraform-stderr")
+	if err != nil {
+		stdoutFile.Close()
+		return 1, "", "", err
+	}
+	defer os.Remove(stderrFile.Name())
+
+	exitCode = runCli(args, stdoutFile, stderrFile, opts)
+
+	stdoutBytes, err := os.ReadFile(stdoutFile.Name())
+	if err != nil {
+		return exitCode, "", "", err
+	}
+	stderrBytes, err := os.ReadFile(stderrFile.Name())
+	if err != nil {
+		return exitCode, string(stdoutBytes), "", err
+	}
+	return exitCode, string(stdoutBytes), string(stderrBytes), nil
+}
+
+// newServices initializes a service discovery object using any credentials
+// and host services configured in the given CLI config.
+func newServices(config *cliconfig.Config) *disco.Disco {
+	// The slightly awkward predeclaration of disco is required to allow us
+	// to pass untyped nil as the creds source when creating the source fails.
+	// Otherwise we pass a typed nil which breaks the nil checks in the disco
+	// object
+	var services *disco.Disco
+	credsSrc, err := credentialsSource(config)
+	if err == nil {
+		services = disco.NewWithCredentialsSource(credsSrc)
+	} else {
+		// Most commands don't actually need credentials, and most situations
+		// that would get us here would already have been reported by the config
+		// loading above, so we'll just log this one as an aid to debugging
+		// in the unlikely event that it _does_ arise.
+		log.Printf("[WARN] Cannot initialize remote host credentials manager: %s", err)
+		// passing (untyped) nil as the creds source is okay because the disco
+		// object checks that and just acts as though no credentials are present.
+		services = disco.NewWithCredentialsSource(nil)
+	}
+	services.SetUserAgent(httpclient.TerraformUserAgent(version.String()))
+
+	// Exports using the services without a Meta still need the services of
+	// the hosts configured in the CLI config, as NewMeta forces them.
+	for userHost, hostConfig := range config.Hosts {
+		host, err := svchost.ForComparison(userHost)
+		if err != nil {
+			continue
 		}
+		services.ForceHostServices(host, hostConfig.Services)
+	}
+	return services
+}
+
+// loadMeta builds a command.Meta for the current working directory the same
+// way RunCli does before dispatching to a command, so that exports can reuse
+// the backend and provider plumbing of the commands directly. Any UI output
+// of the returned Meta is discarded.
+func loadMeta(dataDir string) (command.Meta, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	config, configDiags := cliconfig.LoadConfig()
+	diags = diags.Append(configDiags)
+
+	services := newServices(config)
+
+	providerSrc, providerDiags := providerSource(config.ProviderInstallation, services)
+	diags = diags.Append(providerDiags)
+	providerDevOverrides := providerDevOverrides(config.ProviderInstallation)
+
+	unmanagedProviders, err := parseReattachProviders(os.Getenv("TF_REATTACH_PROVIDERS"))
+	if err != nil {
+		return command.Meta{}, diags.Append(err)
+	}
+
+	backendInit.Init(services)
+
+	streams, err := terminal.Init()
+	if err != nil {
+		return command.Meta{}, diags.Append(fmt.Errorf("Failed to configure the terminal: %s", err))
+	}
+	originalWd, err := os.Getwd()
+	if err != nil {
+		return command.Meta{}, diags.Append(fmt.Errorf("Failed to determine current working directory: %s", err))
+	}
+
+	meta := NewMeta(originalWd, streams, config, services, providerSrc, providerDevOverrides, unmanagedProviders, nil)
+	meta.Ui = &cli.BasicUi{
+		Writer:      io.Discard,
+		ErrorWriter: io.Discard,
+		Reader:      strings.NewReader(""),
+	}
+	if dataDir != "" {
+		meta.WorkingDir.OverrideDataDir(dataDir)
+	}
+	return meta, diags
+}
+
+// chdir switches the process working directory to dir and returns a function
+// restoring the previous one. Like the -chdir option, this is needed because
+// command.Meta resolves the configuration, data dir and backend relative to
+// the current working directory. Other runs and exports depending on the
+// working directory wait until it is restored. If dir is empty, the working
+// directory is kept as it is until the returned function is called.
+func chdir(dir string) (func(), error) {
+	if dir == "" {
+		return rlockCwd(), nil
+	}
+	cwdLock.Lock()
+	wd, err := os.Getwd()
+	if err != nil {
+		cwdLock.Unlock()
+		return nil, err
+	}
+	if err := os.Chdir(dir); err != nil {
+		cwdLock.Unlock()
+		return nil, err
+	}
+	return func() {
+		os.Chdir(wd)
+		cwdLock.Unlock()
+	}, nil
+}
+
+// absDir returns the absolute path of dir, or of the current working
+// directory if dir is empty, for the exports reading files relative to the
+// caller's working directory apart from the runs they make or the working
+// directory they switch to.
+func absDir(dir string) (string, error) {
+	defer rlockCwd()()
+	return filepath.Abs(dir)
+}
+
+// VersionInfo describes the version of the embedded Terraform.
+type VersionInfo struct {
+	Version    string `json:"version"`
+	Prerelease string `json:"prerelease"`
+	Platform   string `json:"platform"`
+	GoVersion  string `json:"go_version"`
+}
+
+//export GetVersion
+func GetVersion() *C.char {
+	versionBytes, err := json.Marshal(&VersionInfo{
+		Version:    Version,
+		Prerelease: VersionPrerelease,
+		Platform:   getproviders.CurrentPlatform.String(),
+		GoVersion:  runtime.Version(),
+	})
+	if err != nil {
+		return C.CString("")
+	}
+	return C.CString(string(versionBytes))
+}
+
+// CommandInfo describes a command of the CLI, where Name is the command name
+// as given on the command line, such as "state list".
+type CommandInfo struct {
+	Name string `json:"name"`
+	// Primary tells whether it is one of the main commands listed first in
+	// the help output.
+	Primary bool `json:"primary"`
+	// Hidden tells whether it is left out of the help output, which is the
+	// case for the subcommands of hidden commands too.
+	Hidden bool `json:"hidden"`
+}
+
+//export ListCommands
+func ListCommands() *C.char {
+	commandsBytes, err := json.Marshal(listCommands())
+	if err != nil {
+		return C.CString("")
 	}
-	return C.int(exitCode)
+	return C.CString(string(commandsBytes))
+}
+
+// listCommands lists the commands registered by NewCommands, sorted by name.
+// The commands are not instantiated, so the Meta they would get is empty.
+func listCommands() []*CommandInfo {
+	commands := NewCommands(command.Meta{})
+	primary := make(map[string]bool, len(PrimaryCommands))
+	for _, name := range PrimaryCommands {
+		primary[name] = true
+	}
+
+	ret := make([]*CommandInfo, 0, len(commands))
+	for name := range commands {
+		_, hidden := HiddenCommands[strings.Fields(name)[0]]
+		ret = append(ret, &CommandInfo{
+			Name:    name,
+			Primary: primary[name],
+			Hidden:  hidden,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
 }
 
 func NewMeta(
@@ -733,89 +1384,5005 @@ func NewCommands(meta command.Meta) map[string]cli.CommandFactory {
 }
 
 // **********************************************
-// Config
+// Plan
 // **********************************************
 
-// ShortModule is a container for a set of configuration constructs that are
-// evaluated within a common namespace.
-// Compared with module, there are fewer non-serializable fields.
-type ShortModule struct {
-	SourceDir string
+//export ShowPlanJSON
+func ShowPlanJSON(cPlanPath *C.char, cConfigDir *C.char, cDataDir *C.char) (cPlan *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
 
-	CoreVersionConstraints []configs.VersionConstraint
+	// The plan path is relative to the caller's working directory, so it
+	// has to be resolved before switching to the config dir.
+	planPath, err := absDir(C.GoString(cPlanPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	plan, diags, err := showPlanJSON(planPath, C.GoString(cConfigDir), C.GoString(cDataDir))
+	return toCResult(plan, diags, err)
+}
 
-	ActiveExperiments experiments.Set
+// showPlanJSON renders the saved plan file at planPath in the same JSON
+// representation as "terraform show -json". Reading a plan needs the backend
+// and provider schemas of the working directory it was created in, which is
+// configDir with its data dir at dataDir (defaults to .terraform).
+func showPlanJSON(planPath string, configDir string, dataDir string) (json.RawMessage, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
 
-	Backend              *configs.Backend
-	CloudConfig          *configs.CloudConfig
-	ProviderConfigs      map[string]*configs.Provider
-	ProviderRequirements *configs.RequiredProviders
+	restore, err := chdir(configDir)
+	if err != nil {
+		return nil, diags, err
+	}
+	defer restore()
 
-	Variables map[string]*configs.Variable
-	Locals    map[string]*configs.Local
-	Outputs   map[string]*configs.Output
+	meta, metaDiags := loadMeta(dataDir)
+	diags = diags.Append(metaDiags)
+	if metaDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	defer plugin.OpenClientScope().Close()
 
-	ModuleCalls map[string]*configs.ModuleCall
+	planFile, err := meta.PlanFile(planPath)
+	if err != nil {
+		return nil, diags, err
+	}
+	if planFile == nil {
+		return nil, diags, fmt.Errorf("%s is a directory, not a plan file", planPath)
+	}
+	plan, err := planFile.ReadPlan()
+	if err != nil {
+		// The plan reader rejects plan files written by a different plan
+		// format version or Terraform version than this build.
+		return nil, diags, fmt.Errorf("plan file %s is not compatible with Terraform %s: %s", planPath, version.String(), err)
+	}
+	stateFile, err := planFile.ReadStateFile()
+	if err != nil {
+		return nil, diags, fmt.Errorf("failed to read the prior state from plan file %s: %s", planPath, err)
+	}
 
-	ManagedResources map[string]*configs.Resource
-	DataResources    map[string]*configs.Resource
+	b, backendDiags := meta.Backend(nil)
+	diags = diags.Append(backendDiags)
+	if backendDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	opReq := meta.Operation(b)
+	opReq.PlanFile = planFile
+
+	lr, schemas, runDiags, err := localRun(&meta, b, opReq)
+	diags = diags.Append(runDiags)
+	if err != nil || runDiags.HasErrors() {
+		return nil, diags, err
+	}
+
+	jsonPlan, err := jsonplan.Marshal(lr.Config, plan, stateFile, schemas)
+	if err != nil {
+		return nil, diags, err
+	}
+	return jsonPlan, diags, nil
+}
+
+// RenderPlanSummary renders the summary of a plan given in the JSON format of
+// ShowPlanJSON the way the plan command does, such as "Plan: 3 to add, 1 to
+// change, 0 to destroy.", followed by the changes to the outputs, if any. The
+// summary is colorized if cColor is non-zero. It returns an empty string if
+// the plan JSON is invalid or has actions it doesn't know.
+//
+//export RenderPlanSummary
+func RenderPlanSummary(cPlanJSON *C.char, cColor C.int) *C.char {
+	summary, err := renderPlanSummary([]byte(C.GoString(cPlanJSON)), cColor != 0)
+	if err != nil {
+		return C.CString("")
+	}
+	return C.CString(summary)
+}
+
+// planSummaryJSON is the part of the JSON representation of a plan needed to
+// render its summary.
+type planSummaryJSON struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Mode    string `json:"mode"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+	OutputChanges map[string]struct {
+		Actions         []string        `json:"actions"`
+		Before          json.RawMessage `json:"before"`
+		After           json.RawMessage `json:"after"`
+		AfterUnknown    json.RawMessage `json:"after_unknown"`
+		BeforeSensitive json.RawMessage `json:"before_sensitive"`
+		AfterSensitive  json.RawMessage `json:"after_sensitive"`
+	} `json:"output_changes"`
+}
+
+// jsonPlanActions are the planned actions by their JSON representation, with
+// the actions of a replacement joined by a comma.
+var jsonPlanActions = map[string]plans.Action{
+	"no-op":         plans.NoOp,
+	"create":        plans.Create,
+	"read":          plans.Read,
+	"update":        plans.Update,
+	"delete":        plans.Delete,
+	"delete,create": plans.DeleteThenCreate,
+	"create,delete": plans.CreateThenDelete,
+}
+
+// The texts of a plan summary, which are the ones the plan renderer of the
+// views package prints around the diffs of the resources and outputs.
+const (
+	planSummaryNoChanges       = "[reset][bold][green]No changes.[reset][bold] Your infrastructure matches the configuration.[reset]\n\n"
+	planSummaryNoChangesDetail = "Terraform has compared your real infrastructure against your configuration and found no differences, so no changes are needed.\n"
+	planSummaryCounts          = "[reset][bold]Plan:[reset] %d to add, %d to change, %d to destroy.\n"
+	planSummaryOutputs         = "[reset][bold]Changes to Outputs:[reset]"
+	planSummaryOutputsOnly     = "\nYou can apply this plan to save these new output values to the Terraform state, without changing any real infrastructure.\n"
+)
+
+// renderPlanSummary renders the summary of the given JSON plan. The plan
+// renderer of the views package can't be reused for it: it renders a
+// *plans.Plan with the provider schemas, neither of which can be rebuilt from
+// the JSON plan, and it prints the summary in between the diffs of every
+// resource. So the texts it prints are kept in the constants above, which are
+// checked against the output of the plan command by the tests, while the
+// changes to the outputs, which need no schema, are rendered by the same
+// format.OutputChanges as the plan command.
+func renderPlanSummary(planJSON []byte, color bool) (string, error) {
+	var plan planSummaryJSON
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return "", fmt.Errorf("invalid plan JSON: %s", err)
+	}
+	colorize := &colorstring.Colorize{
+		Colors:  colorstring.DefaultColors,
+		Disable: !color,
+		Reset:   true,
+	}
+
+	counts := map[plans.Action]int{}
+	for _, rc := range plan.ResourceChanges {
+		action, ok := jsonPlanActions[strings.Join(rc.Change.Actions, ",")]
+		if !ok {
+			return "", fmt.Errorf("unknown actions %q of %s", rc.Change.Actions, rc.Address)
+		}
+		if rc.Mode != "managed" {
+			continue
+		}
+		counts[action]++
+	}
+	var outputChanges []*plans.OutputChangeSrc
+	for name, oc := range plan.OutputChanges {
+		action, ok := jsonPlanActions[strings.Join(oc.Actions, ",")]
+		if !ok {
+			return "", fmt.Errorf("unknown actions %q of output %s", oc.Actions, name)
+		}
+		if action == plans.NoOp {
+			continue
+		}
+		before, err := jsonOutputValue(oc.Before, nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid value before the change of output %s: %s", name, err)
+		}
+		after, err := jsonOutputValue(oc.After, oc.AfterUnknown)
+		if err != nil {
+			return "", fmt.Errorf("invalid value after the change of output %s: %s", name, err)
+		}
+		change := &plans.OutputChange{
+			Addr:      addrs.RootModuleInstance.OutputValue(name),
+			Sensitive: jsonTrue(oc.BeforeSensitive) || jsonTrue(oc.AfterSensitive),
+			Change: plans.Change{
+				Action: action,
+				Before: before,
+				After:  after,
+			},
+		}
+		src, err := change.Encode()
+		if err != nil {
+			return "", err
+		}
+		outputChanges = append(outputChanges, src)
+	}
+
+	var sb strings.Builder
+	toAdd := counts[plans.Create] + counts[plans.DeleteThenCreate] + counts[plans.CreateThenDelete]
+	toChange := counts[plans.Update]
+	toDestroy := counts[plans.Delete] + counts[plans.DeleteThenCreate] + counts[plans.CreateThenDelete]
+	if toAdd+toChange+toDestroy == 0 && len(outputChanges) == 0 {
+		sb.WriteString(colorize.Color(planSummaryNoChanges))
+		sb.WriteString(planSummaryNoChangesDetail)
+		return sb.String(), nil
+	}
+
+	if toAdd+toChange+toDestroy > 0 {
+		sb.WriteString(colorize.Color(fmt.Sprintf(planSummaryCounts, toAdd, toChange, toDestroy)))
+	}
+	if len(outputChanges) > 0 {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(colorize.Color(planSummaryOutputs))
+		sb.WriteString(format.OutputChanges(outputChanges, colorize))
+		sb.WriteString("\n")
+		if toAdd+toChange+toDestroy == 0 {
+			sb.WriteString(planSummaryOutputsOnly)
+		}
+	}
+	return sb.String(), nil
+}
+
+// jsonOutputValue converts the value of an output in a JSON plan, which is
+// unknown if its after_unknown is true.
+func jsonOutputValue(raw json.RawMessage, unknown json.RawMessage) (cty.Value, error) {
+	if jsonTrue(unknown) {
+		return cty.DynamicVal, nil
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	}
+	ty, err := ctyjson.ImpliedType(raw)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(raw, ty)
+}
+
+// jsonTrue returns whether the given JSON value is true.
+func jsonTrue(raw json.RawMessage) bool {
+	var b bool
+	return json.Unmarshal(raw, &b) == nil && b
+}
+
+//export ShowStateJSON
+func ShowStateJSON(cWorkingDir *C.char, cDataDir *C.char, cWorkspace *C.char) (cState *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	state, diags, err := showStateJSON(C.GoString(cWorkingDir), C.GoString(cDataDir), C.GoString(cWorkspace))
+	return toCResult(state, diags, err)
+}
+
+// showStateJSON renders the latest state of the given workspace of the
+// working dir, with its data dir at dataDir (defaults to .terraform), in the
+// same JSON representation as "terraform show -json" without a plan. The
+// workspace defaults to the selected one, which can be overridden by the
+// TF_WORKSPACE environment variable. Without any state, the JSON
+// representation of an empty state is returned.
+func showStateJSON(workingDir string, dataDir string, workspace string) (json.RawMessage, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	restore, err := chdir(workingDir)
+	if err != nil {
+		return nil, diags, err
+	}
+	defer restore()
+
+	meta, metaDiags := loadMeta(dataDir)
+	diags = diags.Append(metaDiags)
+	if metaDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	defer plugin.OpenClientScope().Close()
+
+	b, backendDiags := meta.Backend(nil)
+	diags = diags.Append(backendDiags)
+	if backendDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	stateFile, workspace, err := readState(&meta, b, workspace)
+	if err != nil {
+		return nil, diags, err
+	}
+	if stateFile == nil || stateFile.State.Empty() {
+		jsonState, err := jsonstate.Marshal(stateFile, nil)
+		return jsonState, diags, err
+	}
+
+	opReq := meta.Operation(b)
+	opReq.Workspace = workspace
+	_, schemas, runDiags, err := localRun(&meta, b, opReq)
+	diags = diags.Append(runDiags)
+	if err != nil || runDiags.HasErrors() {
+		return nil, diags, err
+	}
+
+	jsonState, err := jsonstate.Marshal(stateFile, schemas)
+	if err != nil {
+		return nil, diags, err
+	}
+	return jsonState, diags, nil
+}
+
+// readState reads the latest state of the given workspace, which defaults to
+// the selected one, returning it along with the workspace. The state is nil
+// if there is no state yet.
+func readState(meta *command.Meta, b backend.Backend, workspace string) (*statefile.File, string, error) {
+	if workspace == "" {
+		var err error
+		if workspace, err = meta.Workspace(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	stateMgr, err := b.StateMgr(workspace)
+	if err != nil {
+		return nil, workspace, fmt.Errorf("failed to load state of workspace %q: %s", workspace, err)
+	}
+	if err := stateMgr.RefreshState(); err != nil {
+		return nil, workspace, fmt.Errorf("failed to load state of workspace %q: %s", workspace, err)
+	}
+	return statemgr.Export(stateMgr), workspace, nil
+}
+
+//export WorkspaceStateSerial
+func WorkspaceStateSerial(cWorkingDir *C.char, cWorkspace *C.char) (cSerial *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	serial, diags, err := workspaceStateSerial(C.GoString(cWorkingDir), C.GoString(cWorkspace))
+	return toCResult(serial, diags, err)
+}
+
+// workspaceStateSerial returns the serial of the state of the given workspace
+// of the working dir, which is incremented on each change of the state, or
+// nil if there is no state.
+func workspaceStateSerial(workingDir string, workspace string) (*uint64, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	restore, err := chdir(workingDir)
+	if err != nil {
+		return nil, diags, err
+	}
+	defer restore()
+
+	meta, metaDiags := loadMeta("")
+	diags = diags.Append(metaDiags)
+	if metaDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	b, backendDiags := meta.Backend(nil)
+	diags = diags.Append(backendDiags)
+	if backendDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	stateFile, _, err := readState(&meta, b, workspace)
+	if err != nil || stateFile == nil || stateFile.State == nil {
+		return nil, diags, err
+	}
+	return &stateFile.Serial, diags, nil
+}
+
+//export StateProviderVersions
+func StateProviderVersions(cWorkingDir *C.char, cWorkspace *C.char) (cProviders *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	result, diags, err := stateProviderVersions(C.GoString(cWorkingDir), C.GoString(cWorkspace))
+	return toCResult(result, diags, err)
+}
+
+// StateProviders lists the providers of the resources recorded in a state.
+type StateProviders struct {
+	Workspace string
+	// TerraformVersion is the version of Terraform which wrote the state, or
+	// empty if there is no state.
+	TerraformVersion string
+	Providers        []*StateProvider
+}
+
+// StateProvider is a provider of the resources recorded in a state.
+type StateProvider struct {
+	Provider string
+	// Version is the version of the provider selected in the dependency lock
+	// file, since the state itself doesn't record the versions of the
+	// providers. It is empty if the provider is not locked.
+	Version   string
+	Resources []string
+}
+
+// stateProviderVersions lists the providers of the resources recorded in the
+// state of the given workspace of the working dir, sorted by address, along
+// with their versions in the dependency lock file of the working dir.
+func stateProviderVersions(workingDir string, workspace string) (*StateProviders, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	restore, err := chdir(workingDir)
+	if err != nil {
+		return nil, diags, err
+	}
+	defer restore()
+
+	meta, metaDiags := loadMeta("")
+	diags = diags.Append(metaDiags)
+	if metaDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	b, backendDiags := meta.Backend(nil)
+	diags = diags.Append(backendDiags)
+	if backendDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	stateFile, workspace, err := readState(&meta, b, workspace)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	ret := &StateProviders{Workspace: workspace, Providers: []*StateProvider{}}
+	if stateFile == nil || stateFile.State == nil {
+		return ret, diags, nil
+	}
+	if stateFile.TerraformVersion != nil {
+		ret.TerraformVersion = stateFile.TerraformVersion.String()
+	}
+
+	locks, lockDiags := depsfile.LoadLocksFromFile(dependencyLockFile)
+	if lockDiags.HasErrors() {
+		// Without a lock file, the versions are unknown.
+		locks = depsfile.NewLocks()
+	}
+	byProvider := map[addrs.Provider]*StateProvider{}
+	for _, ms := range stateFile.State.Modules {
+		for _, rs := range ms.Resources {
+			provider := rs.ProviderConfig.Provider
+			sp, ok := byProvider[provider]
+			if !ok {
+				sp = &StateProvider{Provider: provider.String()}
+				if lock := locks.Provider(provider); lock != nil {
+					sp.Version = lock.Version().String()
+				}
+				byProvider[provider] = sp
+				ret.Providers = append(ret.Providers, sp)
+			}
+			sp.Resources = append(sp.Resources, rs.Addr.String())
+		}
+	}
+	sort.Slice(ret.Providers, func(i, j int) bool {
+		return ret.Providers[i].Provider < ret.Providers[j].Provider
+	})
+	for _, sp := range ret.Providers {
+		sort.Strings(sp.Resources)
+	}
+	return ret, diags, nil
+}
+
+// dependencyLockFile is the dependency lock file of a working dir, relative
+// to the working dir.
+const dependencyLockFile = ".terraform.lock.hcl"
+
+// localRun prepares the given operation on the configuration in the current
+// working directory the same way the commands do, returning the local run
+// with the provider schemas of its configuration and state.
+func localRun(meta *command.Meta, b backend.Backend, opReq *backend.Operation) (*backend.LocalRun, *terraform.Schemas, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	local, ok := b.(backend.Local)
+	if !ok {
+		return nil, nil, diags, fmt.Errorf("the configured backend %T does not support local operations", b)
+	}
+
+	var err error
+	opReq.ConfigDir = "."
+	opReq.AllowUnsetVariables = true
+	opReq.ConfigLoader, err = configload.NewLoader(&configload.Config{
+		ModulesDir: filepath.Join(meta.DataDir(), "modules"),
+		Services:   meta.Services,
+	})
+	if err != nil {
+		return nil, nil, diags, err
+	}
+
+	lr, _, ctxDiags := local.LocalRun(opReq)
+	diags = diags.Append(ctxDiags)
+	if ctxDiags.HasErrors() {
+		return nil, nil, diags, nil
+	}
+	schemas, schemaDiags := lr.Core.Schemas(lr.Config, lr.InputState)
+	diags = diags.Append(schemaDiags)
+	if schemaDiags.HasErrors() {
+		return nil, nil, diags, nil
+	}
+	return lr, schemas, diags, nil
+}
+
+//export ValidateConfig
+func ValidateConfig(cWorkingDir *C.char) (cResult *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	result, diags, err := validateConfig(C.GoString(cWorkingDir))
+	return toCResult(result, diags, err)
+}
+
+// ValidateResult is the result of ValidateConfig, like the output of
+// "terraform validate -json" without the diagnostics.
+type ValidateResult struct {
+	Valid        bool
+	ErrorCount   int
+	WarningCount int
+}
+
+// validateConfig validates the configuration in the working dir the same way
+// as the validate command, loading the installed modules and providers but
+// without any variables or state. The diagnostics of the validation are
+// returned in diags.
+func validateConfig(workingDir string) (*ValidateResult, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	restore, err := chdir(workingDir)
+	if err != nil {
+		return nil, diags, err
+	}
+	defer restore()
+
+	// Like the validate command, problems with the CLI config don't count
+	// toward the validation.
+	meta, metaDiags := loadMeta("")
+	if metaDiags.HasErrors() {
+		return nil, metaDiags, nil
+	}
+	defer plugin.OpenClientScope().Close()
+
+	config, configDiags, err := loadWorkingDirConfig(&meta)
+	if err != nil {
+		return nil, diags, err
+	}
+	diags = diags.Append(configDiags)
+	if configDiags.HasErrors() {
+		return newValidateResult(diags), diags, nil
+	}
+
+	factories, factoryDiags := installedProviderFactories(config, filepath.Join(meta.DataDir(), "providers"))
+	diags = diags.Append(factoryDiags)
+	if factoryDiags.HasErrors() {
+		return newValidateResult(diags), diags, nil
+	}
+
+	tfCtx, ctxDiags := terraform.NewContext(&terraform.ContextOpts{
+		Providers:    factories,
+		Provisioners: provisionerFactories(),
+	})
+	diags = diags.Append(ctxDiags)
+	if ctxDiags.HasErrors() {
+		return newValidateResult(diags), diags, nil
+	}
+	diags = diags.Append(tfCtx.Validate(config))
+	return newValidateResult(diags), diags, nil
+}
+
+// loadWorkingDirConfig loads the configuration in the current working dir
+// with the modules installed in the data dir of meta.
+func loadWorkingDirConfig(meta *command.Meta) (*configs.Config, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	loader, err := configload.NewLoader(&configload.Config{
+		ModulesDir: filepath.Join(meta.DataDir(), "modules"),
+		Services:   meta.Services,
+	})
+	if err != nil {
+		return nil, diags, err
+	}
+	config, hclDiags := loader.LoadConfig(".")
+	return config, diags.Append(hclDiags), nil
+}
+
+func newValidateResult(diags tfdiags.Diagnostics) *ValidateResult {
+	ret := &ValidateResult{Valid: !diags.HasErrors()}
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Error {
+			ret.ErrorCount++
+		} else {
+			ret.WarningCount++
+		}
+	}
+	return ret
+}
+
+//export FindRemovableProviders
+func FindRemovableProviders(cWorkingDir *C.char, cWorkspace *C.char) (cProviders *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	result, diags, err := findRemovableProviders(C.GoString(cWorkingDir), C.GoString(cWorkspace))
+	return toCResult(result, diags, err)
+}
+
+// RemovableProvider is a provider recorded in the state or the dependency
+// lock file which the configuration no longer requires.
+type RemovableProvider struct {
+	Provider string
+	// Version is the version of the provider selected in the dependency lock
+	// file, which is empty if it is not locked.
+	Version string
+	// Resources are the resources still recorded in the state with the
+	// provider. Until they are destroyed by an apply, the provider is still
+	// needed.
+	Resources []string
+}
+
+// findRemovableProviders finds the providers recorded in the state of the
+// given workspace of the working dir or in its dependency lock file which are
+// not required by its configuration, sorted by address.
+func findRemovableProviders(workingDir string, workspace string) ([]*RemovableProvider, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	restore, err := chdir(workingDir)
+	if err != nil {
+		return nil, diags, err
+	}
+	defer restore()
+
+	meta, metaDiags := loadMeta("")
+	diags = diags.Append(metaDiags)
+	if metaDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	config, configDiags, err := loadWorkingDirConfig(&meta)
+	if err != nil {
+		return nil, diags, err
+	}
+	diags = diags.Append(configDiags)
+	if configDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	reqs, reqDiags := config.ProviderRequirements()
+	diags = diags.Append(reqDiags)
+	if reqDiags.HasErrors() {
+		return nil, diags, nil
+	}
+
+	b, backendDiags := meta.Backend(nil)
+	diags = diags.Append(backendDiags)
+	if backendDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	stateFile, _, err := readState(&meta, b, workspace)
+	if err != nil {
+		return nil, diags, err
+	}
+	locks, lockDiags := depsfile.LoadLocksFromFile(dependencyLockFile)
+	if lockDiags.HasErrors() {
+		locks = depsfile.NewLocks()
+	}
+
+	byProvider := map[addrs.Provider]*RemovableProvider{}
+	removable := func(provider addrs.Provider) *RemovableProvider {
+		if _, required := reqs[provider]; required {
+			return nil
+		}
+		rp, ok := byProvider[provider]
+		if !ok {
+			rp = &RemovableProvider{Provider: provider.String(), Resources: []string{}}
+			if lock := locks.Provider(provider); lock != nil {
+				rp.Version = lock.Version().String()
+			}
+			byProvider[provider] = rp
+		}
+		return rp
+	}
+	for provider := range locks.AllProviders() {
+		removable(provider)
+	}
+	if stateFile != nil && stateFile.State != nil {
+		for _, ms := range stateFile.State.Modules {
+			for _, rs := range ms.Resources {
+				if rp := removable(rs.ProviderConfig.Provider); rp != nil {
+					rp.Resources = append(rp.Resources, rs.Addr.String())
+				}
+			}
+		}
+	}
+
+	ret := make([]*RemovableProvider, 0, len(byProvider))
+	for _, rp := range byProvider {
+		sort.Strings(rp.Resources)
+		ret = append(ret, rp)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Provider < ret[j].Provider
+	})
+	return ret, diags, nil
+}
+
+//export ApplyWithLimit
+func ApplyWithLimit(cWorkingDir *C.char, cVarsJSON *C.char, cMaxChanges C.int) (cResult *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	result, err := applyWithLimit(C.GoString(cWorkingDir), C.GoString(cVarsJSON), int(cMaxChanges))
+	return toCResult(result, nil, err)
+}
+
+// LimitedApplyResult is the result of ApplyWithLimit, listing the addresses
+// of the resource instances whose changes were applied and the ones whose
+// changes remain to be applied.
+type LimitedApplyResult struct {
+	Applied   []string
+	Remaining []string
+}
+
+// applyWithLimit applies at most maxChanges of the resource instance changes
+// planned for the working dir, by applying the plan saved by limitedPlan. The
+// remaining changes are the ones of a follow-up plan.
+func applyWithLimit(workingDir string, varsJSON string, maxChanges int) (*LimitedApplyResult, error) {
+	if maxChanges < 0 {
+		return nil, fmt.Errorf("invalid max changes %d, must not be negative", maxChanges)
+	}
+	vars, err := varArgs(varsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	planPath, limited, planned, err := limitedPlan(workingDir, vars, maxChanges)
+	if planPath != "" {
+		defer os.Remove(planPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(limited) == 0 {
+		return &LimitedApplyResult{Applied: []string{}, Remaining: changeAddrs(planned)}, nil
+	}
+
+	// The variables are saved in the plan, and can't be given with it.
+	exitCode, _, stderr, err := runCommand(chdirArg(workingDir), "apply", "-input=false", "-no-color", planPath)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("apply exited with code %d: %s", exitCode, stderr)
+	}
+
+	plan, err := createPlan(workingDir, vars...)
+	if err != nil {
+		return nil, err
+	}
+	return &LimitedApplyResult{
+		Applied:   changeAddrs(limited),
+		Remaining: changeAddrs(resourceChanges(plan)),
+	}, nil
+}
+
+// limitedPlan saves a plan of the working dir with at most maxChanges
+// resource instance changes, returning the path of the plan file, which is
+// empty if no plan is saved, its changes and all the planned changes.
+//
+// If the planned changes exceed maxChanges, the plan targets the planned
+// changes in address order. Since targeting a change also plans the pending
+// changes of its dependencies, each target is kept only if the targeted plan
+// still fits, which is checked by planning it.
+func limitedPlan(workingDir string, vars []string, maxChanges int) (string, []*plans.ResourceInstanceChangeSrc, []*plans.ResourceInstanceChangeSrc, error) {
+	planPath, plan, err := savePlan(workingDir, vars...)
+	if err != nil {
+		return planPath, nil, nil, err
+	}
+	planned := resourceChanges(plan)
+	if len(planned) <= maxChanges {
+		return planPath, planned, planned, nil
+	}
+	os.Remove(planPath)
+	if maxChanges == 0 {
+		return "", nil, planned, nil
+	}
+
+	var targets []string
+	var limited []*plans.ResourceInstanceChangeSrc
+	limitedPath := ""
+	for _, change := range planned {
+		candidate := append(append([]string{}, targets...), "-target="+change.Addr.String())
+		planPath, plan, err := savePlan(workingDir, append(candidate, vars...)...)
+		if err != nil {
+			if planPath != "" {
+				os.Remove(planPath)
+			}
+			return limitedPath, nil, nil, err
+		}
+		targeted := resourceChanges(plan)
+		if len(targeted) > maxChanges {
+			os.Remove(planPath)
+			continue
+		}
+		if limitedPath != "" {
+			os.Remove(limitedPath)
+		}
+		targets, limited, limitedPath = candidate, targeted, planPath
+		if len(targeted) == maxChanges {
+			break
+		}
+	}
+	return limitedPath, limited, planned, nil
+}
+
+//export PlanChangeSummaries
+func PlanChangeSummaries(cWorkingDir *C.char, cVarsJSON *C.char) (cSummaries *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	summaries, err := planChangeSummaries(C.GoString(cWorkingDir), C.GoString(cVarsJSON))
+	return toCResult(summaries, nil, err)
+}
+
+// changeVerbs are the verbs describing the planned actions in the summaries
+// of PlanChangeSummaries.
+var changeVerbs = map[plans.Action]string{
+	plans.Create:           "Create",
+	plans.Update:           "Update",
+	plans.Delete:           "Destroy",
+	plans.CreateThenDelete: "Replace",
+	plans.DeleteThenCreate: "Replace",
+}
+
+// planChangeSummaries plans the working dir and returns a one-line summary of
+// each resource instance change, such as "Create aws_instance.web", sorted by
+// address.
+func planChangeSummaries(workingDir string, varsJSON string) ([]string, error) {
+	vars, err := varArgs(varsJSON)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := createPlan(workingDir, vars...)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []string{}
+	for _, change := range resourceChanges(plan) {
+		verb, ok := changeVerbs[change.Action]
+		if !ok {
+			verb = change.Action.String()
+		}
+		summaries = append(summaries, fmt.Sprintf("%s %s", verb, change.Addr))
+	}
+	return summaries, nil
+}
+
+//export TargetBlastRadius
+func TargetBlastRadius(cWorkingDir *C.char, cTarget *C.char, cVarsJSON *C.char) (cAddrs *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	affected, err := targetBlastRadius(C.GoString(cWorkingDir), C.GoString(cTarget), C.GoString(cVarsJSON))
+	return toCResult(affected, nil, err)
+}
+
+// targetBlastRadius returns the addresses of the resource instances affected
+// by a change of the given target, sorted: the changes of the plan targeting
+// it, which include the changes of its dependencies, and the changes of its
+// dependents in the full plan.
+//
+// The dependents are found from the references in the root module, so a
+// target or dependent in a child module stands for its whole module call.
+func targetBlastRadius(workingDir string, target string, varsJSON string) ([]string, error) {
+	vars, err := varArgs(varsJSON)
+	if err != nil {
+		return nil, err
+	}
+	targetAddr, targetDiags := addrs.ParseTargetStr(target)
+	if targetDiags.HasErrors() {
+		return nil, fmt.Errorf("invalid target %q: %s", target, targetDiags.Err())
+	}
+
+	targeted, err := createPlan(workingDir, append([]string{"-target=" + target}, vars...)...)
+	if err != nil {
+		return nil, err
+	}
+	full, err := createPlan(workingDir, vars...)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := absDir(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	mod, _, err := loadModule(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetNode string
+	switch subject := targetAddr.Subject.(type) {
+	case addrs.AbsResource:
+		targetNode = dependencyNode(subject.Module, subject.Resource)
+	case addrs.AbsResourceInstance:
+		targetNode = dependencyNode(subject.Module, subject.Resource.Resource)
+	case addrs.ModuleInstance:
+		targetNode = dependencyNode(subject, addrs.Resource{})
+	}
+	dependents := moduleDependents(mod, targetNode)
+
+	affected := map[string]struct{}{}
+	for _, change := range resourceChanges(targeted) {
+		affected[change.Addr.String()] = struct{}{}
+	}
+	for _, change := range resourceChanges(full) {
+		if _, ok := dependents[dependencyNode(change.Addr.Module, change.Addr.Resource.Resource)]; ok {
+			affected[change.Addr.String()] = struct{}{}
+		}
+	}
+	ret := make([]string, 0, len(affected))
+	for addr := range affected {
+		ret = append(ret, addr)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// dependencyNode returns the node of the dependency graph of the root module
+// of the given resource, which is its module call if it is in a child module.
+func dependencyNode(module addrs.ModuleInstance, resource addrs.Resource) string {
+	if len(module) > 0 {
+		return "module." + module[0].Name
+	}
+	return resource.String()
+}
+
+// moduleDependents returns the nodes of the resources, data resources, local
+// values and module calls of the module depending on the given node, directly
+// or not, including the node itself.
+func moduleDependents(mod *configs.Module, node string) map[string]struct{} {
+	// dependents are the nodes referring to each node.
+	dependents := map[string][]string{}
+	addRefs := func(from string, traversals []hcl.Traversal) {
+		for _, traversal := range traversals {
+			if to := traversalNode(traversal); to != "" && to != from {
+				dependents[to] = append(dependents[to], from)
+			}
+		}
+	}
+	for _, r := range moduleResources(mod) {
+		from := r.Addr().String()
+		addRefs(from, bodyTraversals(r.Config))
+		for _, expr := range []hcl.Expression{r.Count, r.ForEach} {
+			if expr != nil {
+				addRefs(from, expr.Variables())
+			}
+		}
+		addRefs(from, r.DependsOn)
+	}
+	for name, l := range mod.Locals {
+		addRefs("local."+name, l.Expr.Variables())
+	}
+	for name, mc := range mod.ModuleCalls {
+		from := "module." + name
+		addRefs(from, bodyTraversals(mc.Config))
+		for _, expr := range []hcl.Expression{mc.Count, mc.ForEach} {
+			if expr != nil {
+				addRefs(from, expr.Variables())
+			}
+		}
+		addRefs(from, mc.DependsOn)
+	}
+
+	ret := map[string]struct{}{node: {}}
+	queue := []string{node}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[next] {
+			if _, ok := ret[dependent]; !ok {
+				ret[dependent] = struct{}{}
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	return ret
+}
+
+// traversalNode returns the node of the dependency graph of a module the
+// traversal refers to, or "" if it refers to something else, such as a
+// variable.
+func traversalNode(traversal hcl.Traversal) string {
+	var names []string
+	for _, step := range traversal {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			names = append(names, step.Name)
+		case hcl.TraverseAttr:
+			names = append(names, step.Name)
+		}
+		if len(names) == 3 {
+			break
+		}
+	}
+	if len(names) < 2 {
+		return ""
+	}
+	switch names[0] {
+	case "var", "path", "terraform", "count", "each", "self":
+		return ""
+	case "data":
+		if len(names) < 3 {
+			return ""
+		}
+		return strings.Join(names[:3], ".")
+	default:
+		return strings.Join(names[:2], ".")
+	}
+}
+
+// bodyTraversals returns the traversals of the expressions of the body and of
+// its nested blocks, which are only found in native syntax bodies.
+func bodyTraversals(body hcl.Body) []hcl.Traversal {
+	syntaxBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+	var ret []hcl.Traversal
+	for _, attr := range syntaxBody.Attributes {
+		ret = append(ret, attr.Expr.Variables()...)
+	}
+	for _, block := range syntaxBody.Blocks {
+		ret = append(ret, bodyTraversals(block.Body)...)
+	}
+	return ret
+}
+
+//export PlanReplacementStrategy
+func PlanReplacementStrategy(cWorkingDir *C.char, cVarsJSON *C.char) (cStrategies *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	strategies, err := planReplacementStrategy(C.GoString(cWorkingDir), C.GoString(cVarsJSON))
+	return toCResult(strategies, nil, err)
+}
+
+// ReplacementStrategy is how a resource instance planned to be replaced will
+// be replaced, which is either "create_before_destroy" or
+// "destroy_before_create".
+type ReplacementStrategy struct {
+	Address  string
+	Strategy string
+}
+
+// planReplacementStrategy plans the working dir and returns the strategy of
+// each resource instance replacement, sorted by address.
+func planReplacementStrategy(workingDir string, varsJSON string) ([]*ReplacementStrategy, error) {
+	vars, err := varArgs(varsJSON)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := createPlan(workingDir, vars...)
+	if err != nil {
+		return nil, err
+	}
+
+	strategies := []*ReplacementStrategy{}
+	for _, change := range resourceChanges(plan) {
+		var strategy string
+		switch change.Action {
+		case plans.CreateThenDelete:
+			strategy = "create_before_destroy"
+		case plans.DeleteThenCreate:
+			strategy = "destroy_before_create"
+		default:
+			continue
+		}
+		strategies = append(strategies, &ReplacementStrategy{
+			Address:  change.Addr.String(),
+			Strategy: strategy,
+		})
+	}
+	return strategies, nil
+}
+
+//export PlanApprovalRequired
+func PlanApprovalRequired(cWorkingDir *C.char, cVarsJSON *C.char, cPolicyJSON *C.char) (cChanges *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	changes, err := planApprovalRequired(C.GoString(cWorkingDir), C.GoString(cVarsJSON), C.GoString(cPolicyJSON))
+	return toCResult(changes, nil, err)
+}
+
+// approvalPolicy is the policy of PlanApprovalRequired, whose rules tell the
+// changes requiring approval.
+type approvalPolicy struct {
+	Rules []*approvalRule `json:"rules"`
+}
+
+// approvalRule matches the changes having any of its Actions, as named in
+// JSON plans, to resources whose address matches any of its Addresses
+// patterns (see addressMatch) and whose type is any of its ResourceTypes.
+// Empty lists match any change.
+type approvalRule struct {
+	Name          string   `json:"name"`
+	Actions       []string `json:"actions"`
+	Addresses     []string `json:"addresses"`
+	ResourceTypes []string `json:"resource_types"`
+}
+
+// ApprovalRequired is a resource instance change requiring approval, with
+// the names of the rules of the policy it matches.
+type ApprovalRequired struct {
+	Address string
+	Actions []string
+	Rules   []string
+}
+
+// planApprovalRequired plans the working dir and returns the resource
+// instance changes matching any rule of the given JSON policy, sorted by
+// address. The actions of a replacement are both delete and create, so that
+// a rule for deletes also matches replacements.
+func planApprovalRequired(workingDir string, varsJSON string, policyJSON string) ([]*ApprovalRequired, error) {
+	var policy approvalPolicy
+	if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy JSON: %s", err)
+	}
+	vars, err := varArgs(varsJSON)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := createPlan(workingDir, vars...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*ApprovalRequired{}
+	for _, change := range resourceChanges(plan) {
+		var actions []string
+		for name, action := range jsonPlanActions {
+			if action == change.Action {
+				actions = strings.Split(name, ",")
+			}
+		}
+		var rules []string
+		for _, rule := range policy.Rules {
+			if rule.matches(change, actions) {
+				rules = append(rules, rule.Name)
+			}
+		}
+		if len(rules) > 0 {
+			ret = append(ret, &ApprovalRequired{
+				Address: change.Addr.String(),
+				Actions: actions,
+				Rules:   rules,
+			})
+		}
+	}
+	return ret, nil
+}
+
+func (r *approvalRule) matches(change *plans.ResourceInstanceChangeSrc, actions []string) bool {
+	if len(r.Actions) > 0 && !containsAny(r.Actions, actions) {
+		return false
+	}
+	if len(r.ResourceTypes) > 0 && !containsAny(r.ResourceTypes, []string{change.Addr.Resource.Resource.Type}) {
+		return false
+	}
+	if len(r.Addresses) == 0 {
+		return true
+	}
+	addr := change.Addr.String()
+	for _, pattern := range r.Addresses {
+		if addressMatch(pattern, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressMatch returns whether addr matches the pattern, in which * matches
+// any sequence of characters. Unlike filepath.Match, nothing else is special,
+// so that the brackets and quotes of instance keys, as in
+// module.x["a"].aws_instance.web[0], are matched literally.
+func addressMatch(pattern string, addr string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == addr
+	}
+	if !strings.HasPrefix(addr, parts[0]) {
+		return false
+	}
+	addr = addr[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(addr, part)
+		if i < 0 {
+			return false
+		}
+		addr = addr[i+len(part):]
+	}
+	return strings.HasSuffix(addr, parts[len(parts)-1])
+}
+
+// containsAny returns whether any of the values is in list.
+func containsAny(list []string, values []string) bool {
+	for _, item := range list {
+		for _, value := range values {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//export VerifyIdempotent
+func VerifyIdempotent(cWorkingDir *C.char, cVarsJSON *C.char, cApply C.int) (cResult *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	result, err := verifyIdempotent(C.GoString(cWorkingDir), C.GoString(cVarsJSON), cApply != 0)
+	return toCResult(result, nil, err)
+}
+
+// IdempotencyResult tells whether planning again after an apply would change
+// anything, listing the resource instances which would change.
+type IdempotencyResult struct {
+	Idempotent bool
+	Changes    []*PlannedChange
+}
+
+// PlannedChange is a planned resource instance change, where Action is the
+// name of the planned action, such as "Update" or "DeleteThenCreate".
+type PlannedChange struct {
+	Address string
+	Action  string
+}
+
+// verifyIdempotent applies the working dir if apply is set, or else assumes
+// it is already applied, and then plans it again to check that the plan has
+// no changes.
+func verifyIdempotent(workingDir string, varsJSON string, apply bool) (*IdempotencyResult, error) {
+	vars, err := varArgs(varsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if apply {
+		args := []string{chdirArg(workingDir), "apply", "-input=false", "-no-color", "-auto-approve"}
+		if exitCode, _, stderr, err := runCommand(append(args, vars...)...); err != nil {
+			return nil, err
+		} else if exitCode != 0 {
+			return nil, fmt.Errorf("apply exited with code %d: %s", exitCode, stderr)
+		}
+	}
+
+	plan, err := createPlan(workingDir, vars...)
+	if err != nil {
+		return nil, err
+	}
+	result := &IdempotencyResult{Changes: []*PlannedChange{}}
+	for _, change := range resourceChanges(plan) {
+		result.Changes = append(result.Changes, &PlannedChange{
+			Address: change.Addr.String(),
+			Action:  change.Action.String(),
+		})
+	}
+	result.Idempotent = len(result.Changes) == 0
+	return result, nil
+}
+
+// createPlan runs the plan command for the working dir with the given extra
+// args and reads back the saved plan.
+func createPlan(workingDir string, args ...string) (*plans.Plan, error) {
+	planPath, plan, err := savePlan(workingDir, args...)
+	if planPath != "" {
+		os.Remove(planPath)
+	}
+	return plan, err
+}
+
+// savePlan is like createPlan, also returning the path of the temporary plan
+// file, which the caller must remove, so that the plan can be applied.
+func savePlan(workingDir string, args ...string) (string, *plans.Plan, error) {
+	planFile, err := os.CreateTemp("", "libterraform-*.tfplan")
+	if err != nil {
+		return "", nil, err
+	}
+	planFile.Close()
+	planPath := planFile.Name()
+
+	planArgs := []string{chdirArg(workingDir), "plan", "-input=false", "-no-color", "-out=" + planPath}
+	exitCode, _, stderr, err := runCommand(append(planArgs, args...)...)
+	if err != nil {
+		return planPath, nil, err
+	}
+	if exitCode != 0 {
+		return planPath, nil, fmt.Errorf("plan exited with code %d: %s", exitCode, stderr)
+	}
+
+	reader, err := planfile.Open(planPath)
+	if err != nil {
+		return planPath, nil, err
+	}
+	defer reader.Close()
+	plan, err := reader.ReadPlan()
+	return planPath, plan, err
+}
+
+// resourceChanges returns the managed resource instance changes of the plan
+// which are not no-ops, sorted by address.
+func resourceChanges(plan *plans.Plan) []*plans.ResourceInstanceChangeSrc {
+	var changes []*plans.ResourceInstanceChangeSrc
+	for _, change := range plan.Changes.Resources {
+		if change.Action == plans.NoOp || change.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Addr.Less(changes[j].Addr)
+	})
+	return changes
+}
+
+func changeAddrs(changes []*plans.ResourceInstanceChangeSrc) []string {
+	ret := make([]string, 0, len(changes))
+	for _, change := range changes {
+		ret = append(ret, change.Addr.String())
+	}
+	return ret
+}
+
+// chdirArg returns the -chdir option running a command in the working dir.
+func chdirArg(workingDir string) string {
+	if workingDir == "" {
+		workingDir = "."
+	}
+	return "-chdir=" + workingDir
+}
+
+// varArgs converts a JSON object of variable values into -var options.
+// Strings are passed as they are and any other values as their JSON text,
+// which is also valid HCL syntax for the variables of complex types.
+func varArgs(varsJSON string) ([]string, error) {
+	if varsJSON == "" {
+		return nil, nil
+	}
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(varsJSON), &vars); err != nil {
+		return nil, fmt.Errorf("invalid variables JSON: %s", err)
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(vars))
+	for _, name := range names {
+		value := string(vars[name])
+		var str string
+		if err := json.Unmarshal(vars[name], &str); err == nil {
+			value = str
+		}
+		args = append(args, fmt.Sprintf("-var=%s=%s", name, value))
+	}
+	return args, nil
+}
+
+// **********************************************
+// Providers
+// **********************************************
+
+//export ProviderSchema
+func ProviderSchema(cSource *C.char, cVersion *C.char, cWorkingDir *C.char, cPluginDir *C.char) (cSchema *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	schema, diags, err := providerSchema(C.GoString(cSource), C.GoString(cVersion), C.GoString(cWorkingDir), C.GoString(cPluginDir))
+	return toCResult(schema, diags, err)
+}
+
+// providerSchema loads the schema of the given provider from a package that
+// is already installed, either in pluginDir or else in the data dir of the
+// working dir and then the plugin cache dir. The schema is returned in the
+// same JSON representation as "terraform providers schema -json".
+//
+// The latest installed version is used unless a version is given.
+func providerSchema(source string, versionStr string, workingDir string, pluginDir string) (json.RawMessage, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	addr, addrDiags := addrs.ParseProviderSourceString(source)
+	diags = diags.Append(addrDiags)
+	if addrDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	var ver getproviders.Version
+	if versionStr != "" {
+		var err error
+		ver, err = getproviders.ParseVersion(versionStr)
+		if err != nil {
+			return nil, diags, fmt.Errorf("invalid provider version %q: %s", versionStr, err)
+		}
+	}
+
+	cached := findCachedProvider(addr, ver, providerDirs(workingDir, pluginDir))
+	if cached == nil {
+		what := addr.String()
+		if versionStr != "" {
+			what = fmt.Sprintf("%s v%s", addr, versionStr)
+		}
+		return nil, diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Provider not installed",
+			fmt.Sprintf("Provider %s is not available locally. Install it by running \"terraform init\" or by adding it to the plugin cache.", what),
+		)), nil
+	}
+
+	schemas, schemaDiags := loadProviderSchemas(map[addrs.Provider]*providercache.CachedProvider{addr: cached})
+	diags = diags.Append(schemaDiags)
+	if schemaDiags.HasErrors() {
+		return nil, diags, nil
+	}
+	jsonSchema, err := jsonprovider.Marshal(schemas)
+	if err != nil {
+		return nil, diags, err
+	}
+	return jsonSchema, diags, nil
+}
+
+//export LoadDependencyLock
+func LoadDependencyLock(cWorkingDir *C.char) (cLock *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	lock, diags := loadDependencyLock(C.GoString(cWorkingDir))
+	return toCResult(lock, diags, nil)
+}
+
+// DependencyLock is the content of the dependency lock file of a working dir.
+type DependencyLock struct {
+	// Exists is false if the working dir has no dependency lock file, such as
+	// before its first init.
+	Exists    bool
+	Providers []*LockedProvider
+}
+
+// LockedProvider is a provider selected in a dependency lock file.
+type LockedProvider struct {
+	Source      string
+	Version     string
+	Constraints string
+	Hashes      []string
+}
+
+// loadDependencyLock parses the dependency lock file of the given working dir
+// with the providers sorted by source address.
+func loadDependencyLock(workingDir string) (*DependencyLock, tfdiags.Diagnostics) {
+	ret := &DependencyLock{Providers: []*LockedProvider{}}
+
+	filename := filepath.Join(workingDir, dependencyLockFile)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return ret, nil
+	}
+	ret.Exists = true
+	locks, diags := depsfile.LoadLocksFromFile(filename)
+	if diags.HasErrors() {
+		return ret, diags
+	}
+
+	for addr, lock := range locks.AllProviders() {
+		hashes := make([]string, 0, len(lock.AllHashes()))
+		for _, hash := range lock.AllHashes() {
+			hashes = append(hashes, hash.String())
+		}
+		ret.Providers = append(ret.Providers, &LockedProvider{
+			Source:      addr.String(),
+			Version:     lock.Version().String(),
+			Constraints: getproviders.VersionConstraintsString(lock.VersionConstraints()),
+			Hashes:      hashes,
+		})
+	}
+	sort.Slice(ret.Providers, func(i, j int) bool {
+		return ret.Providers[i].Source < ret.Providers[j].Source
+	})
+	return ret, diags
+}
+
+// dataDirPath returns the path of the data dir of the working dir, which is
+// given by the TF_DATA_DIR environment variable or else .terraform.
+func dataDirPath(workingDir string) string {
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(workingDir, dataDir)
+	}
+	return dataDir
+}
+
+//export InitWorkingDir
+func InitWorkingDir(cWorkingDir *C.char, cOptionsJSON *C.char) (cResult *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	result, diags, err := initWorkingDir(C.GoString(cWorkingDir), C.GoString(cOptionsJSON))
+	return toCResult(result, diags, err)
+}
+
+// initOptions are the options of InitWorkingDir. Backend and Get default to
+// true like the -backend and -get options of init, and PluginDir,
+// PluginCacheDir and CliConfigFile allow to init without network access from
+// local copies of the providers.
+type initOptions struct {
+	Upgrade        bool   `json:"upgrade"`
+	Backend        *bool  `json:"backend"`
+	Get            *bool  `json:"get"`
+	PluginDir      string `json:"plugin_dir"`
+	PluginCacheDir string `json:"plugin_cache_dir"`
+	CliConfigFile  string `json:"cli_config_file"`
+}
+
+// InitResult describes what init did in a working dir.
+type InitResult struct {
+	// Backend is the type of the initialized backend, such as local or s3,
+	// and is empty if the backend was not initialized.
+	Backend string
+	// BackendChanged is true if the backend type is not the one the working
+	// dir was initialized with before.
+	BackendChanged bool
+	// Providers are the providers selected in the dependency lock file.
+	Providers []*InitProvider
+	// Upgraded is true if any provider was selected at another version than
+	// before.
+	Upgraded bool
+}
+
+// InitProvider is a provider selected by init, where PreviousVersion is the
+// version selected before, or empty if the provider was not.
+type InitProvider struct {
+	Source          string
+	Version         string
+	PreviousVersion string
+}
+
+// initWorkingDir runs init in the given working dir with the given JSON
+// options and compares the backend and dependency lock file before and after.
+// If init fails, its error output is reported in diags.
+func initWorkingDir(workingDir string, optionsJSON string) (*InitResult, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	var opts initOptions
+	if optionsJSON != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return nil, diags, fmt.Errorf("invalid init options JSON: %s", err)
+		}
+	}
+	args := []string{chdirArg(workingDir), "init", "-input=false", "-no-color"}
+	if opts.Upgrade {
+		args = append(args, "-upgrade")
+	}
+	if opts.Backend != nil && !*opts.Backend {
+		args = append(args, "-backend=false")
+	}
+	if opts.Get != nil && !*opts.Get {
+		args = append(args, "-get=false")
+	}
+	if opts.PluginDir != "" {
+		args = append(args, "-plugin-dir="+opts.PluginDir)
+	}
+
+	dir, err := absDir(workingDir)
+	if err != nil {
+		return nil, diags, err
+	}
+	previousBackend, err := initializedBackend(dir)
+	if err != nil {
+		return nil, diags, err
+	}
+	previousLock, _ := loadDependencyLock(dir)
+	previousVersions := make(map[string]string, len(previousLock.Providers))
+	for _, p := range previousLock.Providers {
+		previousVersions[p.Source] = p.Version
+	}
+
+	runOpts := runOptions{
+		PluginCacheDir: opts.PluginCacheDir,
+		CliConfigFile:  opts.CliConfigFile,
+	}
+	exitCode, _, stderr, err := runCommandWithOptions(runOpts, args...)
+	if err != nil {
+		return nil, diags, err
+	}
+	if exitCode != 0 {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to initialize",
+			strings.TrimSpace(stderr),
+		))
+	}
+
+	ret := &InitResult{Providers: []*InitProvider{}}
+	if opts.Backend == nil || *opts.Backend {
+		ret.Backend, err = initializedBackend(dir)
+		if err != nil {
+			return nil, diags, err
+		}
+		ret.BackendChanged = ret.Backend != previousBackend
+	}
+	lock, lockDiags := loadDependencyLock(dir)
+	diags = diags.Append(lockDiags)
+	for _, p := range lock.Providers {
+		previousVersion := previousVersions[p.Source]
+		ret.Providers = append(ret.Providers, &InitProvider{
+			Source:          p.Source,
+			Version:         p.Version,
+			PreviousVersion: previousVersion,
+		})
+		if previousVersion != "" && previousVersion != p.Version {
+			ret.Upgraded = true
+		}
+	}
+	return ret, diags, nil
+}
+
+// initializedBackend returns the type of the backend the working dir is
+// initialized with, as recorded in the data dir, which is local if there is
+// no record of a backend.
+func initializedBackend(workingDir string) (string, error) {
+	src, err := os.ReadFile(filepath.Join(dataDirPath(workingDir), "terraform.tfstate"))
+	if os.IsNotExist(err) {
+		return "local", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var state struct {
+		Backend *struct {
+			Type string `json:"type"`
+		} `json:"backend"`
+	}
+	if err := json.Unmarshal(src, &state); err != nil {
+		return "", fmt.Errorf("invalid backend state: %s", err)
+	}
+	if state.Backend == nil || state.Backend.Type == "" {
+		return "local", nil
+	}
+	return state.Backend.Type, nil
+}
+
+// providerDirs returns the directories installed providers are looked up in,
+// which is pluginDir if given, or else the providers dir in the data dir of
+// the working dir followed by the plugin cache dir of the CLI config.
+func providerDirs(workingDir string, pluginDir string) []string {
+	if pluginDir != "" {
+		return []string{pluginDir}
+	}
+	dirs := []string{filepath.Join(dataDirPath(workingDir), "providers")}
+	if config, _ := cliconfig.LoadConfig(); config != nil && config.PluginCacheDir != "" {
+		dirs = append(dirs, config.PluginCacheDir)
+	}
+	return dirs
+}
+
+// findCachedProvider finds the given provider in the first of the dirs that
+// has it installed, with the given version or else its latest version.
+func findCachedProvider(addr addrs.Provider, ver getproviders.Version, dirs []string) *providercache.CachedProvider {
+	for _, dir := range dirs {
+		cacheDir := providercache.NewDir(dir)
+		var cached *providercache.CachedProvider
+		if ver == getproviders.UnspecifiedVersion {
+			cached = cacheDir.ProviderLatestVersion(addr)
+		} else {
+			cached = cacheDir.ProviderVersion(addr, ver)
+		}
+		if cached != nil {
+			return cached
+		}
+	}
+	return nil
+}
+
+// installedProviderFactories returns the factories of the providers required
+// by the configuration, which are the versions selected in the dependency
+// lock file installed in the given providers dir by "terraform init". The
+// providers which are not installed are reported in diags.
+func installedProviderFactories(config *configs.Config, providersDir string) (map[addrs.Provider]providers.Factory, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	reqs, reqDiags := config.ProviderRequirements()
+	diags = diags.Append(reqDiags)
+	if reqDiags.HasErrors() {
+		return nil, diags
+	}
+	locks, lockDiags := depsfile.LoadLocksFromFile(dependencyLockFile)
+	if lockDiags.HasErrors() {
+		// Without a lock file, none of the providers are installed.
+		locks = depsfile.NewLocks()
+	}
+
+	factories := map[addrs.Provider]providers.Factory{
+		addrs.NewBuiltInProvider("terraform"): func() (providers.Interface, error) {
+			return terraformProvider.NewProvider(), nil
+		},
+	}
+	for addr := range reqs {
+		if addr.IsBuiltIn() {
+			continue
+		}
+		var cached *providercache.CachedProvider
+		if lock := locks.Provider(addr); lock != nil {
+			cached = findCachedProvider(addr, lock.Version(), []string{providersDir})
+		}
+		if cached == nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Provider not installed",
+				fmt.Sprintf("Provider %s is required by the configuration but is not installed. Run \"terraform init\" to install it.", addr),
+			))
+			continue
+		}
+		factories[addr] = providerFactory(cached)
+	}
+	return factories, diags
+}
+
+// provisionerFactories returns the factories of the built-in provisioners,
+// the only ones supported.
+func provisionerFactories() map[string]provisioners.Factory {
+	return map[string]provisioners.Factory{
+		"file":        provisioners.FactoryFixed(fileprovisioner.New()),
+		"local-exec":  provisioners.FactoryFixed(localexec.New()),
+		"remote-exec": provisioners.FactoryFixed(remoteexec.New()),
+	}
+}
+
+// loadProviderSchemas starts each of the given providers to fetch its schema.
+func loadProviderSchemas(cachedProviders map[addrs.Provider]*providercache.CachedProvider) (*terraform.Schemas, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	defer plugin.OpenClientScope().Close()
+
+	schemas := &terraform.Schemas{
+		Providers: map[addrs.Provider]*terraform.ProviderSchema{},
+	}
+	for addr, cached := range cachedProviders {
+		provider, err := providerFactory(cached)()
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Failed to start provider",
+				fmt.Sprintf("Could not start provider %s: %s.", addr, err),
+			))
+			continue
+		}
+		resp := provider.GetProviderSchema()
+		provider.Close()
+		diags = diags.Append(resp.Diagnostics)
+		if resp.Diagnostics.HasErrors() {
+			continue
+		}
+
+		schema := &terraform.ProviderSchema{
+			Provider:                   resp.Provider.Block,
+			ProviderMeta:               resp.ProviderMeta.Block,
+			ResourceTypes:              make(map[string]*configschema.Block, len(resp.ResourceTypes)),
+			DataSources:                make(map[string]*configschema.Block, len(resp.DataSources)),
+			ResourceTypeSchemaVersions: make(map[string]uint64, len(resp.ResourceTypes)),
+		}
+		for name, s := range resp.ResourceTypes {
+			schema.ResourceTypes[name] = s.Block
+			schema.ResourceTypeSchemaVersions[name] = uint64(s.Version)
+		}
+		for name, s := range resp.DataSources {
+			schema.DataSources[name] = s.Block
+		}
+		schemas.Providers[addr] = schema
+	}
+	return schemas, diags
+}
+
+// providerFactory returns a factory starting the plugin of the given cached
+// provider, the same way the commands do.
+func providerFactory(cached *providercache.CachedProvider) providers.Factory {
+	return func() (providers.Interface, error) {
+		execFile, err := cached.ExecutableFile()
+		if err != nil {
+			return nil, err
+		}
+
+		config := &plugin.ClientConfig{
+			HandshakeConfig:  tfplugin.Handshake,
+			Logger:           logging.NewProviderLogger(""),
+			AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+			Managed:          true,
+			Cmd:              exec.Command(execFile),
+			AutoMTLS:         os.Getenv("TF_DISABLE_PLUGIN_TLS") == "",
+			VersionedPlugins: tfplugin.VersionedPlugins,
+			SyncStdout:       logging.PluginOutputMonitor(fmt.Sprintf("%s:stdout", cached.Provider)),
+			SyncStderr:       logging.PluginOutputMonitor(fmt.Sprintf("%s:stderr", cached.Provider)),
+		}
+		client := plugin.NewClient(config)
+		rpcClient, err := client.Client()
+		if err != nil {
+			return nil, err
+		}
+		raw, err := rpcClient.Dispense(tfplugin.ProviderPluginName)
+		if err != nil {
+			return nil, err
+		}
+
+		switch client.NegotiatedVersion() {
+		case 5:
+			p := raw.(*tfplugin.GRPCProvider)
+			p.PluginClient = client
+			return p, nil
+		case 6:
+			p := raw.(*tfplugin6.GRPCProvider)
+			p.PluginClient = client
+			return p, nil
+		default:
+			return nil, fmt.Errorf("unsupported plugin protocol version %d", client.NegotiatedVersion())
+		}
+	}
+}
+
+// **********************************************
+// Fmt
+// **********************************************
+
+//export FmtPatch
+func FmtPatch(cPath *C.char) (cPatch *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	patch, diags, err := fmtPatch(C.GoString(cPath))
+	return toCResult(patch, diags, err)
+}
+
+// fmtPatch formats the configuration and variables files in the given
+// directory and its subdirectories like "terraform fmt -recursive", without
+// writing them, and returns the changes as a single unified patch applicable
+// with "git apply" in that directory. Hidden directories, such as the data
+// dir, are skipped. Files which can't be formatted are reported in diags and
+// left out of the patch.
+func fmtPatch(dir string) (string, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	var filenames []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext == ".tf" || ext == ".tfvars" {
+			filenames = append(filenames, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", diags, err
+	}
+	sort.Strings(filenames)
+
+	var patch strings.Builder
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return "", diags, err
+		}
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return "", diags, err
+		}
+		formatted, fmtDiags := fmtSource(src, filename)
+		if fmtDiags.HasErrors() {
+			for _, diag := range fmtDiags {
+				diags = diags.Append(tfdiags.Sourceless(
+					diag.Severity(),
+					fmt.Sprintf("Failed to format %s", filepath.ToSlash(rel)),
+					fmtDiagDetail(diag),
+				))
+			}
+			continue
+		}
+		patch.WriteString(unifiedDiff(filepath.ToSlash(rel), string(src), string(formatted)))
+	}
+	return patch.String(), diags, nil
+}
+
+//export FmtModule
+func FmtModule(cFilesJSON *C.char) (cFiles *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	var files map[string]string
+	if err := json.Unmarshal([]byte(C.GoString(cFilesJSON)), &files); err != nil {
+		return toCResult(nil, nil, fmt.Errorf("invalid files JSON: %s", err))
+	}
+	formatted, diags, err := fmtModule(files)
+	return toCResult(formatted, diags, err)
+}
+
+// fmtModule formats the given sources of configuration and variables files,
+// keyed by file name, like "terraform fmt" without reading or writing any
+// file, and returns the formatted sources keyed by the same names. Files
+// which can't be formatted are reported in diags and left out.
+func fmtModule(files map[string]string) (map[string]string, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	filenames := make([]string, 0, len(files))
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	ret := make(map[string]string, len(files))
+	for _, filename := range filenames {
+		formatted, fmtDiags := fmtSource([]byte(files[filename]), filename)
+		if fmtDiags.HasErrors() {
+			for _, diag := range fmtDiags {
+				diags = diags.Append(tfdiags.Sourceless(
+					diag.Severity(),
+					fmt.Sprintf("Failed to format %s", filename),
+					fmtDiagDetail(diag),
+				))
+			}
+			continue
+		}
+		ret[filename] = string(formatted)
+	}
+	return ret, diags, nil
+}
+
+// fmtSource formats the given source of a configuration or variables file
+// the same way as "terraform fmt", without running the command. Like the
+// command, a source which isn't valid native syntax is not formatted and its
+// syntax errors are returned in diags.
+func fmtSource(src []byte, filename string) ([]byte, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	_, syntaxDiags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if syntaxDiags.HasErrors() {
+		return nil, diags.Append(syntaxDiags)
+	}
+	// FormatSourceCode is added by command_patch.go.
+	return command.FormatSourceCode(src, filename), diags
+}
+
+// fmtDiagDetail describes an error preventing a file from being formatted,
+// such as "Invalid character on line 1: This character is not used within
+// the language.".
+func fmtDiagDetail(diag tfdiags.Diagnostic) string {
+	desc := diag.Description()
+	detail := desc.Summary
+	if subject := diag.Source().Subject; subject != nil {
+		detail += fmt.Sprintf(" on line %d", subject.Start.Line)
+	}
+	if desc.Detail != "" {
+		detail += ": " + desc.Detail
+	}
+	return detail
+}
+
+// diffContextLines is the number of unchanged lines shown around the changes
+// in the hunks of a unified diff, the same as the default of diff and git.
+const diffContextLines = 3
+
+// diffLine is a line of a unified diff, where kind is ' ' for an unchanged
+// line, '-' for a removed line and '+' for an added line. The line includes
+// its newline, except for a last line without one.
+type diffLine struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the git-style unified diff changing the file of the
+// given name from a to b, or "" if they are the same.
+func unifiedDiff(name string, a string, b string) string {
+	if a == b {
+		return ""
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", name, name)
+	fmt.Fprintf(&sb, "--- a/%s\n", name)
+	fmt.Fprintf(&sb, "+++ b/%s\n", name)
+
+	// aLines and bLines are the numbers of the lines of a and b before each
+	// diff line.
+	aLines := make([]int, len(lines)+1)
+	bLines := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if l.kind != '+' {
+			aLines[i+1]++
+		}
+		if l.kind != '-' {
+			bLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].kind == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		// A hunk goes on until a run of unchanged lines too long to join it
+		// with the next change.
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].kind == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContextLines {
+				end += diffContextLines
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = next
+		}
+
+		aStart, aCount := aLines[start], aLines[end]-aLines[start]
+		bStart, bCount := bLines[start], bLines[end]-bLines[start]
+		// Empty ranges start at the line before them.
+		if aCount > 0 {
+			aStart++
+		}
+		if bCount > 0 {
+			bStart++
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, l := range lines[start:end] {
+			sb.WriteByte(l.kind)
+			sb.WriteString(l.line)
+			if !strings.HasSuffix(l.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// splitLines splits s into lines, each including its newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b with the linear space
+// variant of the Myers diff algorithm, where the removed lines of each change
+// come before the added ones.
+func diffLines(a []string, b []string) []diffLine {
+	var lines []diffLine
+	diffLinesInto(&lines, a, b)
+
+	// Within each run of changed lines, move the removed lines first.
+	for start := 0; start < len(lines); {
+		if lines[start].kind == ' ' {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && lines[end].kind != ' ' {
+			end++
+		}
+		sort.SliceStable(lines[start:end], func(i, j int) bool {
+			return lines[start+i].kind == '-' && lines[start+j].kind == '+'
+		})
+		start = end
+	}
+	return lines
+}
+
+// diffLinesInto appends a shortest edit script from a to b to lines. Common
+// leading and trailing lines are kept as they are, and the rest is split at
+// the middle of the edit script, so that only linear space is used.
+func diffLinesInto(lines *[]diffLine, a []string, b []string) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		*lines = append(*lines, diffLine{' ', a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := bisectLines(a, b); ok {
+		diffLinesInto(lines, a[:x], b[:y])
+		diffLinesInto(lines, a[x:], b[y:])
+	} else {
+		for _, line := range a {
+			*lines = append(*lines, diffLine{'-', line})
+		}
+		for _, line := range b {
+			*lines = append(*lines, diffLine{'+', line})
+		}
+	}
+	for _, line := range common {
+		*lines = append(*lines, diffLine{' ', line})
+	}
+}
+
+// bisectLines finds where the forward and backward searches of the Myers
+// diff algorithm meet, which splits a shortest edit script from a to b in
+// two halves, the same way as the bisect of the diff-match-patch library. It
+// returns false if there is no such split, when a or b is empty or all of a
+// is replaced by all of b.
+func bisectLines(a []string, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := (n + m + 1) / 2
+	vOffset := maxD
+	vLength := 2*maxD + 2
+	v1 := make([]int, vLength)
+	v2 := make([]int, vLength)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[vOffset+1] = 0
+	v2[vOffset+1] = 0
+	delta := n - m
+	// If the total number of lines is odd, the forward search collides with
+	// the reverse one, otherwise the reverse search collides with the forward
+	// one.
+	front := delta%2 != 0
+	// Offsets for the start and end of the diagonals, to skip the ones going
+	// out of the edit graph.
+	var k1start, k1end, k2start, k2end int
+	for d := 0; d < maxD; d++ {
+		for k1 := -d + k1start; k1 <= d-k1end; k1 += 2 {
+			k1Offset := vOffset + k1
+			var x1 int
+			if k1 == -d || (k1 != d && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+			if x1 > n {
+				k1end += 2
+			} else if y1 > m {
+				k1start += 2
+			} else if front {
+				k2Offset := vOffset + delta - k1
+				if k2Offset >= 0 && k2Offset < vLength && v2[k2Offset] != -1 {
+					if x2 := n - v2[k2Offset]; x1 >= x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+
+		for k2 := -d + k2start; k2 <= d-k2end; k2 += 2 {
+			k2Offset := vOffset + k2
+			var x2 int
+			if k2 == -d || (k2 != d && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+			if x2 > n {
+				k2end += 2
+			} else if y2 > m {
+				k2start += 2
+			} else if !front {
+				k1Offset := vOffset + delta - k2
+				if k1Offset >= 0 && k1Offset < vLength && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := vOffset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// **********************************************
+// Config
+// **********************************************
+
+// ShortModule is a container for a set of configuration constructs that are
+// evaluated within a common namespace.
+// Compared with module, there are fewer non-serializable fields.
+type ShortModule struct {
+	SourceDir string
+
+	CoreVersionConstraints []configs.VersionConstraint
+
+	ActiveExperiments experiments.Set
+
+	Backend              *configs.Backend
+	CloudConfig          *configs.CloudConfig
+	ProviderConfigs      map[string]*configs.Provider
+	ProviderRequirements *configs.RequiredProviders
+
+	Variables map[string]*configs.Variable
+	Locals    map[string]*configs.Local
+	Outputs   map[string]*configs.Output
+
+	ModuleCalls map[string]*configs.ModuleCall
+
+	// ModuleSources holds the parsed source of each module call, keyed by
+	// the module call name.
+	ModuleSources map[string]*ModuleSource
+
+	ManagedResources map[string]*configs.Resource
+	DataResources    map[string]*configs.Resource
+
+	// ResourceDetails holds a serializable form of each managed and data
+	// resource, keyed by the resource address.
+	ResourceDetails map[string]*ShortResource
 
 	Moved []*configs.Moved
+
+	// Import and Checks hold the top-level import and check blocks, which
+	// only exist since Terraform v1.5. The embedded Terraform reports them as
+	// unsupported, so they are read from the .tf and .tf.json files directly.
+	Import []*ShortImport `json:",omitempty"`
+	Checks []*ShortCheck  `json:",omitempty"`
+}
+
+// ShortImport is an import block, where To and ID are the text of its to and
+// id expressions, or their value if constant, and Provider the provider
+// configuration it uses, if set.
+type ShortImport struct {
+	To        string
+	ID        string
+	Provider  string `json:",omitempty"`
+	DeclRange hcl.Range
+}
+
+// ShortCheck is a check block with the addresses of its scoped data sources
+// and its assertions.
+type ShortCheck struct {
+	Name          string
+	DataResources []string
+	Assertions    []*ShortCheckAssertion
+	DeclRange     hcl.Range
+}
+
+// ShortCheckAssertion is an assert block of a check block, where Condition
+// and ErrorMessage are the text of its expressions, or their value if
+// constant.
+type ShortCheckAssertion struct {
+	Condition    string
+	ErrorMessage string
+	DeclRange    hcl.Range
+}
+
+// importsAndChecksSchema is the schema of the top-level import and check
+// blocks, and checkSchema the one of the blocks nested in a check block.
+var (
+	importsAndChecksSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "import"},
+			{Type: "check", LabelNames: []string{"name"}},
+		},
+	}
+	checkSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "data", LabelNames: []string{"type", "name"}},
+			{Type: "assert"},
+		},
+	}
+)
+
+// convertImportsAndChecks reads the top-level import and check blocks of the
+// .tf and .tf.json files of the module from their sources, which may also
+// hold the sources of other modules, sorted by file name and then position.
+func convertImportsAndChecks(mod *configs.Module, sources map[string][]byte) ([]*ShortImport, []*ShortCheck) {
+	filenames := make([]string, 0, len(sources))
+	for filename := range sources {
+		if (strings.HasSuffix(filename, ".tf") || strings.HasSuffix(filename, ".tf.json")) &&
+			filepath.Dir(filename) == filepath.Clean(mod.SourceDir) {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	var imports []*ShortImport
+	var checks []*ShortCheck
+	for _, filename := range filenames {
+		var file *hcl.File
+		var diags hcl.Diagnostics
+		if strings.HasSuffix(filename, ".tf.json") {
+			file, diags = hcljson.Parse(sources[filename], filename)
+		} else {
+			file, diags = hclsyntax.ParseConfig(sources[filename], filename, hcl.InitialPos)
+		}
+		if diags.HasErrors() {
+			continue
+		}
+		content, _, _ := file.Body.PartialContent(importsAndChecksSchema)
+		var fileImports []*ShortImport
+		var fileChecks []*ShortCheck
+		for _, block := range content.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+			switch block.Type {
+			case "import":
+				imp := &ShortImport{DeclRange: block.DefRange}
+				if attr, ok := attrs["to"]; ok {
+					imp.To = exprString(attr.Expr, sources)
+				}
+				if attr, ok := attrs["id"]; ok {
+					imp.ID = exprString(attr.Expr, sources)
+				}
+				if attr, ok := attrs["provider"]; ok {
+					imp.Provider = exprString(attr.Expr, sources)
+				}
+				fileImports = append(fileImports, imp)
+			case "check":
+				check := &ShortCheck{
+					Name:          block.Labels[0],
+					DataResources: []string{},
+					Assertions:    []*ShortCheckAssertion{},
+					DeclRange:     block.DefRange,
+				}
+				checkContent, _, _ := block.Body.PartialContent(checkSchema)
+				for _, nested := range checkContent.Blocks {
+					switch nested.Type {
+					case "data":
+						check.DataResources = append(check.DataResources, fmt.Sprintf("data.%s.%s", nested.Labels[0], nested.Labels[1]))
+					case "assert":
+						assertion := &ShortCheckAssertion{DeclRange: nested.DefRange}
+						assertAttrs, _ := nested.Body.JustAttributes()
+						if attr, ok := assertAttrs["condition"]; ok {
+							assertion.Condition = exprString(attr.Expr, sources)
+						}
+						if attr, ok := assertAttrs["error_message"]; ok {
+							assertion.ErrorMessage = exprString(attr.Expr, sources)
+						}
+						check.Assertions = append(check.Assertions, assertion)
+					}
+				}
+				fileChecks = append(fileChecks, check)
+			}
+		}
+		// The blocks of a JSON file are grouped by type rather than in order.
+		sort.SliceStable(fileImports, func(i, j int) bool {
+			return fileImports[i].DeclRange.Start.Byte < fileImports[j].DeclRange.Start.Byte
+		})
+		sort.SliceStable(fileChecks, func(i, j int) bool {
+			return fileChecks[i].DeclRange.Start.Byte < fileChecks[j].DeclRange.Start.Byte
+		})
+		imports = append(imports, fileImports...)
+		checks = append(checks, fileChecks...)
+	}
+	return imports, checks
+}
+
+// ShortResource is the serializable form of a resource block. Unlike
+// configs.Resource, the meta-arguments are rendered as strings and booleans
+// instead of HCL expressions.
+type ShortResource struct {
+	Address string
+	Mode    string
+	Type    string
+	Name    string
+
+	// Provider is the fully-qualified address of the provider of the
+	// resource, and ProviderConfig the provider configuration it uses,
+	// such as "aws" or "aws.west".
+	Provider       string
+	ProviderConfig string
+
+	HasCount   bool
+	HasForEach bool
+	DependsOn  []string
+
+	Provisioners        []string
+	CreateBeforeDestroy bool
+	PreventDestroy      bool
+
+	DeclRange hcl.Range
+}
+
+// ModuleSource is the source of a module call as parsed by Terraform. Type is
+// one of local, registry, invalid or, for remote packages, the installation
+// method such as git, hg, http, s3 or gcs. Address is the normalized address
+// of the source, such as "git::https://github.com/org/repo.git" for the
+// "github.com/org/repo" shorthand, and is empty for invalid sources.
+type ModuleSource struct {
+	Raw     string
+	Type    string
+	Address string
+	Version string
+}
+
+// convertModuleSource converts the source of the module call. The parser
+// reports invalid sources in its diagnostics, so they are only marked here.
+func convertModuleSource(mc *configs.ModuleCall) *ModuleSource {
+	source := &ModuleSource{
+		Raw:  mc.SourceAddrRaw,
+		Type: "invalid",
+	}
+	if len(mc.Version.Required) > 0 {
+		source.Version = mc.Version.Required.String()
+	}
+	if mc.SourceAddr == nil {
+		return source
+	}
+	source.Address = mc.SourceAddr.String()
+	switch addr := mc.SourceAddr.(type) {
+	case addrs.ModuleSourceLocal:
+		source.Type = "local"
+	case addrs.ModuleSourceRegistry:
+		source.Type = "registry"
+	case addrs.ModuleSourceRemote:
+		source.Type = remotePackageType(string(addr.PackageAddr))
+	}
+	return source
+}
+
+// remotePackageType returns the installation method of a normalized remote
+// package address, which is either forced, as in "git::https://...", or
+// given by the URL scheme, where https is reported as http.
+func remotePackageType(packageAddr string) string {
+	if i := strings.Index(packageAddr, "::"); i > 0 {
+		return packageAddr[:i]
+	}
+	if i := strings.Index(packageAddr, "://"); i > 0 {
+		if scheme := packageAddr[:i]; scheme != "https" {
+			return scheme
+		}
+	}
+	return "http"
+}
+
+func convertResource(r *configs.Resource) *ShortResource {
+	shortRes := &ShortResource{
+		Address:        r.Addr().String(),
+		Mode:           "managed",
+		Type:           r.Type,
+		Name:           r.Name,
+		ProviderConfig: r.ProviderConfigAddr().StringCompact(),
+		HasCount:       r.Count != nil,
+		HasForEach:     r.ForEach != nil,
+		DependsOn:      make([]string, 0, len(r.DependsOn)),
+		Provisioners:   []string{},
+		DeclRange:      r.DeclRange,
+	}
+	if r.Mode == addrs.DataResourceMode {
+		shortRes.Mode = "data"
+	}
+	if !r.Provider.IsZero() {
+		shortRes.Provider = r.Provider.String()
+	}
+	for _, traversal := range r.DependsOn {
+		shortRes.DependsOn = append(shortRes.DependsOn, traversalString(traversal))
+	}
+	if r.Managed != nil {
+		for _, p := range r.Managed.Provisioners {
+			shortRes.Provisioners = append(shortRes.Provisioners, p.Type)
+		}
+		shortRes.CreateBeforeDestroy = r.Managed.CreateBeforeDestroy
+		shortRes.PreventDestroy = r.Managed.PreventDestroy
+	}
+	return shortRes
+}
+
+func convertModule(mod *configs.Module, sources map[string][]byte) *ShortModule {
+	shortMod := &ShortModule{
+		SourceDir:              mod.SourceDir,
+		CoreVersionConstraints: mod.CoreVersionConstraints,
+		ActiveExperiments:      mod.ActiveExperiments,
+		Backend:                mod.Backend,
+		CloudConfig:            mod.CloudConfig,
+		ProviderRequirements:   mod.ProviderRequirements,
+		Variables:              mod.Variables,
+		Locals:                 mod.Locals,
+		Outputs:                mod.Outputs,
+		ModuleCalls:            mod.ModuleCalls,
+		ModuleSources:          map[string]*ModuleSource{},
+		ManagedResources:       mod.ManagedResources,
+		DataResources:          mod.DataResources,
+		ResourceDetails:        map[string]*ShortResource{},
+		Moved:                  mod.Moved,
+	}
+	for name, mc := range mod.ModuleCalls {
+		shortMod.ModuleSources[name] = convertModuleSource(mc)
+	}
+	shortMod.Import, shortMod.Checks = convertImportsAndChecks(mod, sources)
+	for _, r := range moduleResources(mod) {
+		shortRes := convertResource(r)
+		shortMod.ResourceDetails[shortRes.Address] = shortRes
+	}
+	return shortMod
+}
+
+//export ConfigLoadConfigDir
+func ConfigLoadConfigDir(cPath *C.char) (cMod *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	return configLoadConfigDir(C.GoString(cPath), false)
+}
+
+// ConfigLoadConfigDirWithOptions is like ConfigLoadConfigDir, removing the
+// diagnostics which repeat an earlier one if cDedupe is not 0.
+//
+//export ConfigLoadConfigDirWithOptions
+func ConfigLoadConfigDirWithOptions(cPath *C.char, cDedupe C.int) (cMod *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	return configLoadConfigDir(C.GoString(cPath), cDedupe != 0)
+}
+
+func configLoadConfigDir(path string, dedupe bool) (cMod *C.char, cDiags *C.char, cError *C.char) {
+	parser := configs.NewParser(nil)
+	mod, diags := parser.LoadConfigDir(path)
+	if dedupe {
+		diags = dedupeDiagnostics(diags)
+	}
+	modBytes, err := json.Marshal(convertModule(mod, parser.Sources()))
+	if err != nil {
+		cMod = C.CString("")
+		cDiags = C.CString("")
+		cError = C.CString(err.Error())
+		return cMod, cDiags, cError
+	}
+	diagsBytes, err := json.Marshal(convertHCLDiagnostics(diags))
+	if err != nil {
+		cMod = C.CString(string(modBytes))
+		cDiags = C.CString("")
+		cError = C.CString(err.Error())
+		return cMod, cDiags, cError
+	}
+	cMod = C.CString(string(modBytes))
+	cDiags = C.CString(string(diagsBytes))
+	cError = C.CString("")
+	return cMod, cDiags, cError
+}
+
+//export ConfigLoadModuleTree
+func ConfigLoadModuleTree(cPath *C.char) (cTree *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	tree, diags, err := loadModuleTree(C.GoString(cPath))
+	return toCStrings(tree, diags, err)
+}
+
+// ConfigLoadModuleTreeWithOptions is like ConfigLoadModuleTree, removing the
+// diagnostics which repeat an earlier one if cDedupe is not 0.
+//
+//export ConfigLoadModuleTreeWithOptions
+func ConfigLoadModuleTreeWithOptions(cPath *C.char, cDedupe C.int) (cTree *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	tree, diags, err := loadModuleTree(C.GoString(cPath))
+	if cDedupe != 0 {
+		diags = dedupeModuleDiagnostics(diags)
+	}
+	return toCStrings(tree, diags, err)
+}
+
+// ModuleTreeNode is a module of a configuration together with its child
+// modules, keyed by module call name.
+type ModuleTreeNode struct {
+	// Path is the address of the module, such as module.foo.module.bar, and
+	// is empty for the root module.
+	Path string
+	// Source is the source of the module call, and is empty for the root
+	// module.
+	Source string
+	// Unresolved is set if the module is not loaded, either because its
+	// source is not a local directory, like registry and git modules, or
+	// because the directory could not be read.
+	Unresolved bool
+
+	Module   *ShortModule
+	Children map[string]*ModuleTreeNode
+}
+
+// ModuleDiagnostic is a diagnostic of a configuration, together with the path
+// of the module it originates from.
+type ModuleDiagnostic struct {
+	Module string `json:"module"`
+	*Diagnostic
+}
+
+// loadModuleTree loads the module in the given directory and all of its child
+// modules with local sources, recursively. Any other modules would need to be
+// installed first, so they are reported as unresolved instead.
+func loadModuleTree(path string) (*ModuleTreeNode, []*ModuleDiagnostic, error) {
+	parser := configs.NewParser(nil)
+	rootMod, hclDiags := parser.LoadConfigDir(path)
+	if rootMod == nil {
+		return nil, nil, fmt.Errorf("the given directory %q does not exist at all or could not be opened for some reason", path)
+	}
+	modDiags := map[string]tfdiags.Diagnostics{}
+	modDiags[""] = modDiags[""].Append(hclDiags)
+
+	cfg, buildDiags := configs.BuildConfig(rootMod, localModuleWalker(parser, modDiags))
+	modDiags[""] = modDiags[""].Append(buildDiags)
+
+	paths := make([]string, 0, len(modDiags))
+	for modPath := range modDiags {
+		paths = append(paths, modPath)
+	}
+	sort.Strings(paths)
+	diags := []*ModuleDiagnostic{}
+	for _, modPath := range paths {
+		for _, diag := range convertDiagnostics(modDiags[modPath]) {
+			diags = append(diags, &ModuleDiagnostic{Module: modPath, Diagnostic: diag})
+		}
+	}
+	return convertModuleTree(cfg, "", parser.Sources()), diags, nil
+}
+
+// dedupeModuleDiagnostics removes the diagnostics with the same severity,
+// summary and source range as an earlier one, such as those reported for
+// each call of the same module. The first module reporting it is kept.
+func dedupeModuleDiagnostics(diags []*ModuleDiagnostic) []*ModuleDiagnostic {
+	seen := map[string]struct{}{}
+	ret := make([]*ModuleDiagnostic, 0, len(diags))
+	for _, diag := range diags {
+		key := diag.Severity + "\x00" + diag.Summary
+		if rng := diag.Subject; rng != nil {
+			key += fmt.Sprintf("\x00%s:%d-%d", rng.Filename, rng.Start.Byte, rng.End.Byte)
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		ret = append(ret, diag)
+	}
+	return ret
+}
+
+// localModuleWalker returns a module walker loading the child modules with
+// local sources using the given parser, recording the diagnostics of each
+// module by its path. Other modules are left out of the built config.
+func localModuleWalker(parser *configs.Parser, modDiags map[string]tfdiags.Diagnostics) configs.ModuleWalker {
+	return configs.ModuleWalkerFunc(func(req *configs.ModuleRequest) (*configs.Module, *goversion.Version, hcl.Diagnostics) {
+		source, ok := req.SourceAddr.(addrs.ModuleSourceLocal)
+		if !ok {
+			return nil, nil, nil
+		}
+		// Local modules calling each other would otherwise recurse forever.
+		if len(req.Path) > maxModuleDepth {
+			return nil, nil, hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Module nesting too deep",
+				Detail:   fmt.Sprintf("Module %s is nested more than %d levels deep, which probably means local modules are calling each other.", req.Path, maxModuleDepth),
+				Subject:  req.CallRange.Ptr(),
+			}}
+		}
+
+		dir := filepath.Join(req.Parent.Module.SourceDir, string(source))
+		mod, hclDiags := parser.LoadConfigDir(dir)
+		modPath := req.Path.String()
+		modDiags[modPath] = modDiags[modPath].Append(hclDiags)
+		return mod, nil, nil
+	})
+}
+
+// maxModuleDepth is the deepest nesting of local modules that is loaded.
+const maxModuleDepth = 64
+
+func convertModuleTree(cfg *configs.Config, source string, sources map[string][]byte) *ModuleTreeNode {
+	node := &ModuleTreeNode{
+		Path:     cfg.Path.String(),
+		Source:   source,
+		Module:   convertModule(cfg.Module, sources),
+		Children: map[string]*ModuleTreeNode{},
+	}
+	for name, call := range cfg.Module.ModuleCalls {
+		if child, ok := cfg.Children[name]; ok {
+			node.Children[name] = convertModuleTree(child, call.SourceAddrRaw, sources)
+			continue
+		}
+		node.Children[name] = &ModuleTreeNode{
+			Path:       cfg.Path.Child(name).String(),
+			Source:     call.SourceAddrRaw,
+			Unresolved: true,
+			Children:   map[string]*ModuleTreeNode{},
+		}
+	}
+	return node
+}
+
+//export MovedStatements
+func MovedStatements(cPath *C.char) (cStatements *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	statements, diags, err := movedStatements(C.GoString(cPath))
+	return toCResult(statements, diags, err)
+}
+
+// MovedStatement is a moved block of a module with its endpoints resolved,
+// where Module is the path of the module declaring it, which is empty for the
+// root module.
+type MovedStatement struct {
+	Module    string
+	From      string
+	To        string
+	DeclRange hcl.Range
+	// Problems are the summaries of the problems found with the statement,
+	// which are also reported in the diagnostics.
+	Problems []string
+}
+
+// movedStatements finds the moved blocks of the module in the given directory
+// and its local child modules, sorted by position, and validates them the
+// same way a plan does, but without any state.
+//
+// Since the instance keys of the modules and resources using count or for_each
+// are only known during a plan, these are taken as having no instances.
+func movedStatements(path string) ([]*MovedStatement, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	parser := configs.NewParser(nil)
+	rootMod, hclDiags := parser.LoadConfigDir(path)
+	diags = diags.Append(hclDiags)
+	if rootMod == nil {
+		return nil, diags, fmt.Errorf("the given directory %q does not exist at all or could not be opened for some reason", path)
+	}
+	modDiags := map[string]tfdiags.Diagnostics{}
+	cfg, buildDiags := configs.BuildConfig(rootMod, localModuleWalker(parser, modDiags))
+	diags = diags.Append(buildDiags)
+	for _, d := range modDiags {
+		diags = diags.Append(d)
+	}
+	if diags.HasErrors() {
+		return nil, diags, nil
+	}
+
+	stmts := refactoring.FindMoveStatements(cfg)
+	exp := instances.NewExpander()
+	registerSingleInstances(exp, cfg, addrs.RootModuleInstance)
+	moveDiags := refactoring.ValidateMoves(stmts, cfg, exp.AllInstances())
+
+	ret := make([]*MovedStatement, 0, len(stmts))
+	for i := range stmts {
+		stmt := &stmts[i]
+		ms := &MovedStatement{
+			Module:    stmt.From.Module().String(),
+			From:      stmt.From.String(),
+			To:        stmt.To.String(),
+			DeclRange: stmt.DeclRange.ToHCL(),
+			Problems:  []string{},
+		}
+		for _, diag := range moveDiags {
+			desc := diag.Description()
+			if subject := diag.Source().Subject; subject != nil {
+				if subject.ToHCL() == ms.DeclRange {
+					ms.Problems = append(ms.Problems, desc.Summary)
+				}
+			} else if strings.Contains(desc.Detail, fmt.Sprintf("- %s:", stmt.DeclRange.StartString())) {
+				// The cycles are reported without a subject, listing the
+				// statements of the cycle.
+				ms.Problems = append(ms.Problems, desc.Summary)
+			}
+		}
+		ret = append(ret, ms)
+	}
+	diags = diags.Append(moveDiags)
+
+	// Terraform follows chains of moves, but a destination which is moved
+	// again by another statement is usually a leftover of an earlier refactor.
+	for _, ms := range ret {
+		for _, other := range ret {
+			if other != ms && other.Module == ms.Module && other.From == ms.To {
+				ms.Problems = append(ms.Problems, "Chained move statements")
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagWarning,
+					Summary:  "Chained move statements",
+					Detail:   fmt.Sprintf("The destination %s of this statement is moved again to %s by the statement at %s.", ms.To, other.To, other.DeclRange),
+					Subject:  ms.DeclRange.Ptr(),
+				})
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i].DeclRange, ret[j].DeclRange
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return ret, diags, nil
+}
+
+// registerSingleInstances registers the module calls and resources of the
+// given module instance without count or for_each in the expander as having a
+// single instance, recursively, and the others as having no instances.
+func registerSingleInstances(exp *instances.Expander, cfg *configs.Config, modInst addrs.ModuleInstance) {
+	for _, r := range moduleResources(cfg.Module) {
+		if r.Count == nil && r.ForEach == nil {
+			exp.SetResourceSingle(modInst, r.Addr())
+		} else {
+			exp.SetResourceCount(modInst, r.Addr(), 0)
+		}
+	}
+	for name, mc := range cfg.Module.ModuleCalls {
+		call := addrs.ModuleCall{Name: name}
+		child, ok := cfg.Children[name]
+		if !ok || mc.Count != nil || mc.ForEach != nil {
+			exp.SetModuleCount(modInst, call, 0)
+			continue
+		}
+		exp.SetModuleSingle(modInst, call)
+		registerSingleInstances(exp, child, modInst.Child(name, addrs.NoKey))
+	}
+}
+
+//export ProviderInheritance
+func ProviderInheritance(cPath *C.char) (cInheritance *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	inheritance, diags, err := providerInheritance(C.GoString(cPath))
+	return toCResult(inheritance, diags, err)
+}
+
+// InheritedProvider is a provider configuration a child module gets from its
+// parent, either passed explicitly in the providers argument of its module
+// call or, without that argument, inherited implicitly. InChild is the
+// configuration as named in the child, such as "aws", and InParent as named
+// in the parent, such as "aws.west". Resolved is the absolute address of the
+// configuration it finally refers to, such as
+// provider["registry.terraform.io/hashicorp/aws"].west, which is empty if the
+// parent provides no such configuration.
+type InheritedProvider struct {
+	Module   string
+	InChild  string
+	InParent string
+	Implicit bool
+	Resolved string
+}
+
+// providerInheritance loads the module in the given directory with its local
+// child modules and returns the provider configurations each child module
+// gets from its parent, sorted by module path and then name in the child.
+// A child gets the configurations its resources and its own child modules use
+// which it doesn't declare itself.
+func providerInheritance(path string) ([]*InheritedProvider, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	parser := configs.NewParser(nil)
+	rootMod, hclDiags := parser.LoadConfigDir(path)
+	diags = diags.Append(hclDiags)
+	if rootMod == nil {
+		return nil, diags, fmt.Errorf("the given directory %q does not exist at all or could not be opened for some reason", path)
+	}
+	modDiags := map[string]tfdiags.Diagnostics{}
+	cfg, buildDiags := configs.BuildConfig(rootMod, localModuleWalker(parser, modDiags))
+	diags = diags.Append(buildDiags)
+	for _, d := range modDiags {
+		diags = diags.Append(d)
+	}
+	if cfg == nil {
+		return nil, diags, nil
+	}
+
+	ret := []*InheritedProvider{}
+	var walk func(c *configs.Config)
+	walk = func(c *configs.Config) {
+		if c.Parent != nil {
+			call := c.Parent.Module.ModuleCalls[c.Path[len(c.Path)-1]]
+			for _, addr := range inheritedProviderConfigs(c) {
+				inherited := &InheritedProvider{
+					Module:  c.Path.String(),
+					InChild: addr.StringCompact(),
+				}
+				if parentAddr, ok := passedProviderConfig(call, addr); ok {
+					inherited.InParent = parentAddr.StringCompact()
+					inherited.Implicit = len(call.Providers) == 0
+					if resolved, ok := resolveProviderConfig(c.Parent, parentAddr); ok {
+						inherited.Resolved = resolved.String()
+					}
+				}
+				ret = append(ret, inherited)
+			}
+		}
+		names := make([]string, 0, len(c.Children))
+		for name := range c.Children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			walk(c.Children[name])
+		}
+	}
+	walk(cfg)
+	return ret, diags, nil
+}
+
+// inheritedProviderConfigs returns the provider configurations the module
+// uses without declaring them, sorted, which are the ones of its resources
+// and the ones it passes to or its child modules inherit from it.
+func inheritedProviderConfigs(c *configs.Config) []addrs.LocalProviderConfig {
+	used := map[string]addrs.LocalProviderConfig{}
+	for _, r := range moduleResources(c.Module) {
+		addr := r.ProviderConfigAddr()
+		used[addr.StringCompact()] = addr
+	}
+	for name, call := range c.Module.ModuleCalls {
+		for _, passed := range call.Providers {
+			addr := passed.InParent.Addr()
+			used[addr.StringCompact()] = addr
+		}
+		if child, ok := c.Children[name]; ok && len(call.Providers) == 0 {
+			for _, addr := range inheritedProviderConfigs(child) {
+				if addr.Alias == "" {
+					used[addr.StringCompact()] = addr
+				}
+			}
+		}
+	}
+
+	ret := make([]addrs.LocalProviderConfig, 0, len(used))
+	for key, addr := range used {
+		if _, declared := c.Module.ProviderConfigs[key]; !declared {
+			ret = append(ret, addr)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].StringCompact() < ret[j].StringCompact()
+	})
+	return ret
+}
+
+// passedProviderConfig returns the provider configuration of the parent the
+// module call passes as the given configuration of the child. Without a
+// providers argument, only the default configurations are passed, with the
+// same name.
+func passedProviderConfig(call *configs.ModuleCall, addr addrs.LocalProviderConfig) (addrs.LocalProviderConfig, bool) {
+	if len(call.Providers) == 0 {
+		return addr, addr.Alias == ""
+	}
+	for _, passed := range call.Providers {
+		if passed.InChild.Addr() == addr {
+			return passed.InParent.Addr(), true
+		}
+	}
+	return addrs.LocalProviderConfig{}, false
+}
+
+// resolveProviderConfig follows the given provider configuration of the
+// module up to the module declaring it. A default configuration which the
+// root module doesn't declare is the implied empty configuration of the root
+// module.
+func resolveProviderConfig(c *configs.Config, addr addrs.LocalProviderConfig) (addrs.AbsProviderConfig, bool) {
+	if _, declared := c.Module.ProviderConfigs[addr.StringCompact()]; declared || (c.Parent == nil && addr.Alias == "") {
+		return addrs.AbsProviderConfig{
+			Module:   c.Path,
+			Provider: c.Module.ProviderForLocalConfig(addr),
+			Alias:    addr.Alias,
+		}, true
+	}
+	if c.Parent == nil {
+		return addrs.AbsProviderConfig{}, false
+	}
+	call := c.Parent.Module.ModuleCalls[c.Path[len(c.Path)-1]]
+	parentAddr, ok := passedProviderConfig(call, addr)
+	if !ok {
+		return addrs.AbsProviderConfig{}, false
+	}
+	return resolveProviderConfig(c.Parent, parentAddr)
+}
+
+//export CheckModuleVersions
+func CheckModuleVersions(cPath *C.char, cCliConfigFile *C.char) (cModules *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	config, configDiags := loadCliConfig(C.GoString(cCliConfigFile))
+	diags = diags.Append(configDiags)
+	return toCResult(checkModuleVersions(mod, newServices(config)), diags, nil)
+}
+
+// ModuleVersionCheck tells whether the registry has a version of the module
+// of a module call satisfying its version constraint.
+type ModuleVersionCheck struct {
+	Name        string
+	Source      string
+	Constraint  string
+	Satisfiable bool
+	// Version is the latest version satisfying the constraint, which is
+	// empty if Satisfiable is false.
+	Version string
+	// Error is why the versions of the module could not be queried, in which
+	// case Satisfiable is false.
+	Error     string
+	DeclRange hcl.Range
+}
+
+// checkModuleVersions queries the registry for the versions of the modules
+// of the registry module calls of the module, sorted by name. Module calls
+// with other sources are left out.
+func checkModuleVersions(mod *configs.Module, services *disco.Disco) []*ModuleVersionCheck {
+	client := registry.NewClient(services, nil)
+
+	names := make([]string, 0, len(mod.ModuleCalls))
+	for name := range mod.ModuleCalls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := []*ModuleVersionCheck{}
+	for _, name := range names {
+		mc := mod.ModuleCalls[name]
+		source, ok := mc.SourceAddr.(addrs.ModuleSourceRegistry)
+		if !ok {
+			continue
+		}
+		check := &ModuleVersionCheck{
+			Name:       name,
+			Source:     mc.SourceAddrRaw,
+			Constraint: mc.Version.Required.String(),
+			DeclRange:  mc.DeclRange,
+		}
+		ret = append(ret, check)
+
+		resp, err := client.ModuleVersions(context.Background(), regsrc.ModuleFromRegistryPackageAddr(source.PackageAddr))
+		if err != nil {
+			check.Error = err.Error()
+			continue
+		}
+		var latest *goversion.Version
+		for _, m := range resp.Modules {
+			for _, v := range m.Versions {
+				ver, err := goversion.NewVersion(v.Version)
+				if err != nil || !mc.Version.Required.Check(ver) {
+					continue
+				}
+				if latest == nil || ver.GreaterThan(latest) {
+					latest = ver
+				}
+			}
+		}
+		if latest != nil {
+			check.Satisfiable = true
+			check.Version = latest.String()
+		}
+	}
+	return ret
+}
+
+//export ResourcesInOrder
+func ResourcesInOrder(cPath *C.char) (cResources *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	return toCResult(resourcesInOrder(mod), diags, nil)
+}
+
+// resourcesInOrder returns the managed and data resources of the module in
+// the order they are declared, by file name and then position in the file.
+func resourcesInOrder(mod *configs.Module) []*ShortResource {
+	resources := make([]*ShortResource, 0, len(mod.ManagedResources)+len(mod.DataResources))
+	for _, r := range moduleResources(mod) {
+		resources = append(resources, convertResource(r))
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		ri, rj := resources[i].DeclRange, resources[j].DeclRange
+		if ri.Filename != rj.Filename {
+			return ri.Filename < rj.Filename
+		}
+		return ri.Start.Byte < rj.Start.Byte
+	})
+	return resources
+}
+
+//export CheckProviderSources
+func CheckProviderSources(cPath *C.char) (cProblems *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	return toCResult(checkProviderSources(mod), diags, nil)
+}
+
+// ProviderSourceProblem describes a provider of a module whose source address
+// is not fully-qualified, either in its required_providers entry or because
+// it has no entry at all.
+type ProviderSourceProblem struct {
+	Name     string
+	Source   string
+	Provider string
+	// Reason is one of missing_source, short_source, legacy_source or implied.
+	Reason    string
+	Detail    string
+	DeclRange hcl.Range
+}
+
+// checkProviderSources finds the providers of the module whose source address
+// is ambiguous or legacy, sorted by local name.
+func checkProviderSources(mod *configs.Module) []*ProviderSourceProblem {
+	problems := []*ProviderSourceProblem{}
+	required := map[string]*configs.RequiredProvider{}
+	if mod.ProviderRequirements != nil {
+		required = mod.ProviderRequirements.RequiredProviders
+	}
+
+	for name, req := range required {
+		problem := &ProviderSourceProblem{
+			Name:      name,
+			Source:    req.Source,
+			Provider:  req.Type.String(),
+			DeclRange: req.DeclRange,
+		}
+		parts := strings.Split(req.Source, "/")
+		switch {
+		case req.Source == "":
+			problem.Reason = "missing_source"
+			problem.Detail = fmt.Sprintf("Provider %q has no source, so it is implied to be %s.", name, req.Type)
+		case req.Type.IsLegacy() || (len(parts) >= 2 && parts[len(parts)-2] == addrs.LegacyProviderNamespace):
+			problem.Reason = "legacy_source"
+			problem.Detail = fmt.Sprintf("Provider %q uses the legacy source %q, which is not supported since Terraform v0.13.", name, req.Source)
+		case len(parts) == 1:
+			problem.Reason = "short_source"
+			problem.Detail = fmt.Sprintf("Provider %q uses the short source %q, which is ambiguous. Use a source including the namespace, such as \"hashicorp/%s\".", name, req.Source, req.Source)
+		default:
+			continue
+		}
+		problems = append(problems, problem)
+	}
+
+	// Providers used without a required_providers entry are implied to be
+	// in the hashicorp namespace, which is also ambiguous.
+	implied := map[string]*ProviderSourceProblem{}
+	addImplied := func(name string, provider addrs.Provider, rng hcl.Range) {
+		if _, ok := required[name]; ok {
+			return
+		}
+		if _, ok := implied[name]; ok || provider.IsBuiltIn() {
+			return
+		}
+		implied[name] = &ProviderSourceProblem{
+			Name:      name,
+			Provider:  provider.String(),
+			Reason:    "implied",
+			Detail:    fmt.Sprintf("Provider %q is not declared in required_providers, so it is implied to be %s.", name, provider),
+			DeclRange: rng,
+		}
+	}
+	for _, pc := range mod.ProviderConfigs {
+		addImplied(pc.Name, mod.ImpliedProviderForUnqualifiedType(pc.Name), pc.DeclRange)
+	}
+	for _, r := range moduleResources(mod) {
+		addImplied(r.ProviderConfigAddr().LocalName, r.Provider, r.DeclRange)
+	}
+	for _, problem := range implied {
+		problems = append(problems, problem)
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Name < problems[j].Name
+	})
+	return problems
+}
+
+//export SecretReferences
+func SecretReferences(cPath *C.char) (cSecrets *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, sources, diags, err := loadModuleWithSources(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	return toCResult(secretReferences(mod, sources), diags, nil)
+}
+
+// secretDataSources are the data sources known to read secrets from a secret
+// manager, with the arguments identifying the secret they read.
+var secretDataSources = map[string][]string{
+	"aws_secretsmanager_secret":            {"arn", "name"},
+	"aws_secretsmanager_secret_version":    {"secret_id", "version_id", "version_stage"},
+	"aws_ssm_parameter":                    {"name"},
+	"azurerm_key_vault_secret":             {"name", "key_vault_id"},
+	"google_secret_manager_secret_version": {"secret", "version", "project"},
+	"vault_generic_secret":                 {"path"},
+	"vault_kv_secret":                      {"path"},
+	"vault_kv_secret_v2":                   {"mount", "name"},
+}
+
+// SecretReference is a data source reading a secret from a secret manager.
+type SecretReference struct {
+	Address string
+	Type    string
+	// Keys are the arguments identifying the secret, with the static value
+	// of each argument or else the source code of its expression.
+	Keys      map[string]string
+	DeclRange hcl.Range
+}
+
+// secretReferences finds the data sources of the module reading secrets,
+// sorted by address.
+func secretReferences(mod *configs.Module, sources map[string][]byte) []*SecretReference {
+	refs := []*SecretReference{}
+	for _, r := range moduleResources(mod) {
+		keys, ok := secretDataSources[r.Type]
+		if !ok || r.Mode != addrs.DataResourceMode {
+			continue
+		}
+		schema := &hcl.BodySchema{}
+		for _, key := range keys {
+			schema.Attributes = append(schema.Attributes, hcl.AttributeSchema{Name: key})
+		}
+		content, _, _ := r.Config.PartialContent(schema)
+
+		ref := &SecretReference{
+			Address:   r.Addr().String(),
+			Type:      r.Type,
+			Keys:      map[string]string{},
+			DeclRange: r.DeclRange,
+		}
+		for name, attr := range content.Attributes {
+			ref.Keys[name] = exprString(attr.Expr, sources)
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+//export AttackSurfaceSummary
+func AttackSurfaceSummary(cPath *C.char) (cResources *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	ctx, ctxDiags := evalContext(mod, "")
+	diags = diags.Append(ctxDiags)
+	return toCResult(attackSurfaceSummary(mod, ctx), diags, nil)
+}
+
+// ExposedResource is a resource which may be publicly exposed, with the
+// reasons it is considered exposed.
+type ExposedResource struct {
+	Address   string
+	Type      string
+	Reasons   []string
+	DeclRange hcl.Range
+}
+
+// exposureChecks are heuristics telling why a resource of a type may be
+// publicly exposed, based on the static values of its arguments.
+var exposureChecks = map[string]func(body hcl.Body, ctx *hcl.EvalContext) []string{
+	"aws_security_group": func(body hcl.Body, ctx *hcl.EvalContext) []string {
+		var reasons []string
+		for _, ingress := range nestedBlocks(body, "ingress") {
+			reasons = append(reasons, openIngressReasons(ingress, ctx, "cidr_blocks", "ipv6_cidr_blocks")...)
+		}
+		return reasons
+	},
+	"aws_security_group_rule": func(body hcl.Body, ctx *hcl.EvalContext) []string {
+		if t, _ := attrString(body, "type", ctx); t != "ingress" {
+			return nil
+		}
+		return openIngressReasons(body, ctx, "cidr_blocks", "ipv6_cidr_blocks")
+	},
+	"aws_vpc_security_group_ingress_rule": func(body hcl.Body, ctx *hcl.EvalContext) []string {
+		return openIngressReasons(body, ctx, "cidr_ipv4", "cidr_ipv6")
+	},
+	"aws_s3_bucket":     publicACLReasons,
+	"aws_s3_bucket_acl": publicACLReasons,
+	"aws_s3_bucket_public_access_block": func(body hcl.Body, ctx *hcl.EvalContext) []string {
+		var reasons []string
+		for _, name := range []string{"block_public_acls", "block_public_policy", "ignore_public_acls", "restrict_public_buckets"} {
+			if enabled, ok := attrBool(body, name, ctx); ok && !enabled {
+				reasons = append(reasons, fmt.Sprintf("public access block has %s disabled", name))
+			}
+		}
+		return reasons
+	},
+	"aws_instance": func(body hcl.Body, ctx *hcl.EvalContext) []string {
+		if public, _ := attrBool(body, "associate_public_ip_address", ctx); public {
+			return []string{"instance has a public IP address"}
+		}
+		return nil
+	},
+	"aws_db_instance":          publiclyAccessibleReasons,
+	"aws_rds_cluster_instance": publiclyAccessibleReasons,
+	"aws_lb":                   internetFacingReasons,
+	"aws_alb":                  internetFacingReasons,
+	"google_compute_firewall": func(body hcl.Body, ctx *hcl.EvalContext) []string {
+		if direction, ok := attrString(body, "direction", ctx); ok && direction != "INGRESS" {
+			return nil
+		}
+		return openIngressReasons(body, ctx, "source_ranges")
+	},
+	"google_storage_bucket_iam_member":  publicMemberReasons,
+	"google_storage_bucket_iam_binding": publicMemberReasons,
+	"azurerm_network_security_rule":     openSecurityRuleReasons,
+	"azurerm_network_security_group": func(body hcl.Body, ctx *hcl.EvalContext) []string {
+		var reasons []string
+		for _, rule := range nestedBlocks(body, "security_rule") {
+			reasons = append(reasons, openSecurityRuleReasons(rule, ctx)...)
+		}
+		return reasons
+	},
+}
+
+// openCIDRs are the address ranges matching any address.
+var openCIDRs = map[string]bool{
+	"0.0.0.0/0": true,
+	"::/0":      true,
+}
+
+func openIngressReasons(body hcl.Body, ctx *hcl.EvalContext, cidrAttrs ...string) []string {
+	var reasons []string
+	for _, name := range cidrAttrs {
+		for _, cidr := range attrStrings(body, name, ctx) {
+			if openCIDRs[cidr] {
+				reasons = append(reasons, fmt.Sprintf("ingress open to %s%s", cidr, portRange(body, ctx)))
+			}
+		}
+	}
+	return reasons
+}
+
+// portRange describes the ports of an ingress rule, if they are known.
+func portRange(body hcl.Body, ctx *hcl.EvalContext) string {
+	from, fromOK := attrString(body, "from_port", ctx)
+	to, toOK := attrString(body, "to_port", ctx)
+	switch {
+	case fromOK && toOK && from == to:
+		return " on port " + from
+	case fromOK && toOK:
+		return fmt.Sprintf(" on ports %s-%s", from, to)
+	case len(attrStrings(body, "ports", ctx)) > 0:
+		return " on ports " + strings.Join(attrStrings(body, "ports", ctx), ", ")
+	}
+	return ""
+}
+
+func publicACLReasons(body hcl.Body, ctx *hcl.EvalContext) []string {
+	acl, _ := attrString(body, "acl", ctx)
+	if acl == "public-read" || acl == "public-read-write" {
+		return []string{fmt.Sprintf("bucket ACL %s grants public access", acl)}
+	}
+	return nil
+}
+
+func publiclyAccessibleReasons(body hcl.Body, ctx *hcl.EvalContext) []string {
+	if public, _ := attrBool(body, "publicly_accessible", ctx); public {
+		return []string{"database is publicly accessible"}
+	}
+	return nil
+}
+
+func internetFacingReasons(body hcl.Body, ctx *hcl.EvalContext) []string {
+	if internal, ok := attrBool(body, "internal", ctx); !ok || !internal {
+		return []string{"load balancer is internet-facing"}
+	}
+	return nil
+}
+
+func publicMemberReasons(body hcl.Body, ctx *hcl.EvalContext) []string {
+	var reasons []string
+	members := append(attrStrings(body, "member", ctx), attrStrings(body, "members", ctx)...)
+	for _, member := range members {
+		if member == "allUsers" || member == "allAuthenticatedUsers" {
+			reasons = append(reasons, fmt.Sprintf("bucket is accessible by %s", member))
+		}
+	}
+	return reasons
+}
+
+func openSecurityRuleReasons(body hcl.Body, ctx *hcl.EvalContext) []string {
+	direction, _ := attrString(body, "direction", ctx)
+	access, _ := attrString(body, "access", ctx)
+	if !strings.EqualFold(direction, "Inbound") || !strings.EqualFold(access, "Allow") {
+		return nil
+	}
+	var reasons []string
+	prefixes := append(attrStrings(body, "source_address_prefix", ctx), attrStrings(body, "source_address_prefixes", ctx)...)
+	for _, prefix := range prefixes {
+		if prefix == "*" || prefix == "Internet" || openCIDRs[prefix] {
+			reasons = append(reasons, fmt.Sprintf("inbound rule allows traffic from %s", prefix))
+		}
+	}
+	return reasons
+}
+
+// attackSurfaceSummary finds the managed resources of the module which may be
+// publicly exposed, sorted by address. Arguments whose values can't be
+// evaluated statically are not considered.
+func attackSurfaceSummary(mod *configs.Module, ctx *hcl.EvalContext) []*ExposedResource {
+	ret := []*ExposedResource{}
+	for _, r := range moduleResources(mod) {
+		check, ok := exposureChecks[r.Type]
+		if !ok || r.Mode != addrs.ManagedResourceMode {
+			continue
+		}
+		if reasons := check(r.Config, ctx); len(reasons) > 0 {
+			ret = append(ret, &ExposedResource{
+				Address:   r.Addr().String(),
+				Type:      r.Type,
+				Reasons:   reasons,
+				DeclRange: r.DeclRange,
+			})
+		}
+	}
+	return ret
+}
+
+// nestedBlocks returns the bodies of the nested blocks of the given type.
+func nestedBlocks(body hcl.Body, blockType string) []hcl.Body {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: blockType}},
+	})
+	bodies := make([]hcl.Body, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		bodies = append(bodies, block.Body)
+	}
+	return bodies
+}
+
+// attrValue statically evaluates the attribute of the body, returning
+// cty.NilVal if it is not set, null or not wholly known.
+func attrValue(body hcl.Body, name string, ctx *hcl.EvalContext) cty.Value {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: name}},
+	})
+	attr, ok := content.Attributes[name]
+	if !ok {
+		return cty.NilVal
+	}
+	val, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() || val.IsNull() || !val.IsWhollyKnown() {
+		return cty.NilVal
+	}
+	return val
+}
+
+func attrString(body hcl.Body, name string, ctx *hcl.EvalContext) (string, bool) {
+	val := attrValue(body, name, ctx)
+	if val == cty.NilVal {
+		return "", false
+	}
+	str, err := convert.Convert(val, cty.String)
+	if err != nil {
+		return "", false
+	}
+	return str.AsString(), true
+}
+
+func attrBool(body hcl.Body, name string, ctx *hcl.EvalContext) (bool, bool) {
+	val := attrValue(body, name, ctx)
+	if val == cty.NilVal {
+		return false, false
+	}
+	b, err := convert.Convert(val, cty.Bool)
+	if err != nil {
+		return false, false
+	}
+	return b.True(), true
+}
+
+// attrStrings returns the strings of an attribute which is either a string
+// or a collection of strings.
+func attrStrings(body hcl.Body, name string, ctx *hcl.EvalContext) []string {
+	val := attrValue(body, name, ctx)
+	if val == cty.NilVal {
+		return nil
+	}
+	if !val.CanIterateElements() {
+		if str, err := convert.Convert(val, cty.String); err == nil {
+			return []string{str.AsString()}
+		}
+		return nil
+	}
+	var strs []string
+	for it := val.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		if str, err := convert.Convert(elem, cty.String); err == nil && !str.IsNull() {
+			strs = append(strs, str.AsString())
+		}
+	}
+	return strs
+}
+
+//export ComplexityScore
+func ComplexityScore(cPath *C.char) (cScore *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, sources, diags, err := loadModuleWithSources(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	return toCResult(complexityScore(mod, sources), diags, nil)
+}
+
+// Complexity is a heuristic measure of how complex a module is to read and
+// maintain, made of counts of the constructs adding to its complexity.
+type Complexity struct {
+	Resources      int
+	Modules        int
+	Conditionals   int
+	DynamicBlocks  int
+	Interpolations int
+	// Score is the weighted sum of the counts, see complexityWeights.
+	Score int
+}
+
+// complexityWeights are the weights of the counts in the score of a module.
+var complexityWeights = struct {
+	Resources, Modules, Conditionals, DynamicBlocks, Interpolations int
+}{
+	Resources:      1,
+	Modules:        2,
+	Conditionals:   2,
+	DynamicBlocks:  3,
+	Interpolations: 1,
+}
+
+// complexityScore computes the complexity of the module. Constructs are only
+// counted in the native syntax files of the module, not in JSON files.
+func complexityScore(mod *configs.Module, sources map[string][]byte) *Complexity {
+	ret := &Complexity{
+		Resources: len(mod.ManagedResources) + len(mod.DataResources),
+		Modules:   len(mod.ModuleCalls),
+	}
+
+	filenames := make([]string, 0, len(sources))
+	for filename := range sources {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		if !strings.HasSuffix(filename, ".tf") {
+			continue
+		}
+		file, diags := hclsyntax.ParseConfig(sources[filename], filename, hcl.InitialPos)
+		if diags.HasErrors() {
+			continue
+		}
+		hclsyntax.VisitAll(file.Body.(*hclsyntax.Body), func(node hclsyntax.Node) hcl.Diagnostics {
+			switch n := node.(type) {
+			case *hclsyntax.ConditionalExpr:
+				ret.Conditionals++
+			case *hclsyntax.Block:
+				if n.Type == "dynamic" {
+					ret.DynamicBlocks++
+				}
+			case *hclsyntax.TemplateWrapExpr:
+				ret.Interpolations++
+			case *hclsyntax.TemplateExpr:
+				for _, part := range n.Parts {
+					if _, literal := part.(*hclsyntax.LiteralValueExpr); !literal {
+						ret.Interpolations++
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	w := complexityWeights
+	ret.Score = ret.Resources*w.Resources +
+		ret.Modules*w.Modules +
+		ret.Conditionals*w.Conditionals +
+		ret.DynamicBlocks*w.DynamicBlocks +
+		ret.Interpolations*w.Interpolations
+	return ret
+}
+
+//export FindDeprecatedAttributes
+func FindDeprecatedAttributes(cPath *C.char, cSchemasJSON *C.char) (cResources *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	path := C.GoString(cPath)
+	mod, diags, err := loadModule(path)
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+
+	schemasJSON := []byte(C.GoString(cSchemasJSON))
+	if len(schemasJSON) == 0 {
+		schemas, schemaDiags := installedProviderSchemas(moduleProviders(mod), path)
+		diags = diags.Append(schemaDiags)
+		if schemasJSON, err = jsonprovider.Marshal(schemas); err != nil {
+			return toCResult(nil, diags, err)
+		}
+	}
+	var schemas providerSchemasJSON
+	if err := json.Unmarshal(schemasJSON, &schemas); err != nil {
+		return toCResult(nil, diags, fmt.Errorf("invalid provider schemas JSON: %s", err))
+	}
+
+	resources, findDiags := findDeprecatedAttributes(mod, &schemas)
+	return toCResult(resources, diags.Append(findDiags), nil)
+}
+
+// providerSchemasJSON is the part of the JSON representation of provider
+// schemas, as output by "terraform providers schema -json", telling which
+// attributes and blocks are deprecated.
+type providerSchemasJSON struct {
+	ProviderSchemas map[string]*struct {
+		ResourceSchemas   map[string]*schemaJSON `json:"resource_schemas"`
+		DataSourceSchemas map[string]*schemaJSON `json:"data_source_schemas"`
+	} `json:"provider_schemas"`
+}
+
+type schemaJSON struct {
+	Block *blockJSON `json:"block"`
+}
+
+type blockJSON struct {
+	Attributes map[string]*struct {
+		Deprecated bool `json:"deprecated"`
+	} `json:"attributes"`
+	BlockTypes map[string]*struct {
+		Block *blockJSON `json:"block"`
+	} `json:"block_types"`
+	Deprecated bool `json:"deprecated"`
+}
+
+// DeprecatedAttributes lists the deprecated attributes and blocks a resource
+// sets, by their path in the resource, such as "root_block_device.iops".
+type DeprecatedAttributes struct {
+	Address    string
+	Provider   string
+	Attributes []string
+	DeclRange  hcl.Range
+}
+
+// findDeprecatedAttributes finds the resources of the module setting any
+// attributes or blocks the schemas of their providers mark as deprecated,
+// sorted by address.
+func findDeprecatedAttributes(mod *configs.Module, schemas *providerSchemasJSON) ([]*DeprecatedAttributes, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	ret := []*DeprecatedAttributes{}
+	missing := map[addrs.Provider]bool{}
+	for _, r := range moduleResources(mod) {
+		var schema *schemaJSON
+		if provider, ok := schemas.ProviderSchemas[r.Provider.String()]; ok {
+			if r.Mode == addrs.DataResourceMode {
+				schema = provider.DataSourceSchemas[r.Type]
+			} else {
+				schema = provider.ResourceSchemas[r.Type]
+			}
+		} else if !missing[r.Provider] {
+			missing[r.Provider] = true
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Warning,
+				"Missing provider schema",
+				fmt.Sprintf("There is no schema for provider %s, so its resources are not checked for deprecated attributes.", r.Provider),
+			))
+		}
+		if schema == nil || schema.Block == nil {
+			continue
+		}
+
+		attrs := deprecatedAttributes(r.Config, schema.Block, "")
+		if len(attrs) == 0 {
+			continue
+		}
+		sort.Strings(attrs)
+		ret = append(ret, &DeprecatedAttributes{
+			Address:    r.Addr().String(),
+			Provider:   r.Provider.String(),
+			Attributes: attrs,
+			DeclRange:  r.DeclRange,
+		})
+	}
+	return ret, diags
+}
+
+// deprecatedAttributes returns the paths of the deprecated attributes and
+// blocks set in the body, including the ones generated by dynamic blocks.
+func deprecatedAttributes(body hcl.Body, block *blockJSON, prefix string) []string {
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "dynamic", LabelNames: []string{"type"}}},
+	}
+	for name := range block.Attributes {
+		schema.Attributes = append(schema.Attributes, hcl.AttributeSchema{Name: name})
+	}
+	for name := range block.BlockTypes {
+		schema.Blocks = append(schema.Blocks, hcl.BlockHeaderSchema{Type: name})
+	}
+	content, _, _ := body.PartialContent(schema)
+
+	var ret []string
+	for name := range content.Attributes {
+		if block.Attributes[name].Deprecated {
+			ret = append(ret, prefix+name)
+		}
+	}
+	seen := map[string]bool{}
+	for _, b := range content.Blocks {
+		name, nested := b.Type, b.Body
+		if b.Type == "dynamic" {
+			name = b.Labels[0]
+			dynContent, _, _ := b.Body.PartialContent(&hcl.BodySchema{
+				Blocks: []hcl.BlockHeaderSchema{{Type: "content"}},
+			})
+			if len(dynContent.Blocks) == 0 {
+				continue
+			}
+			nested = dynContent.Blocks[0].Body
+		}
+		blockType, ok := block.BlockTypes[name]
+		if !ok || blockType.Block == nil {
+			continue
+		}
+		if blockType.Block.Deprecated && !seen[name] {
+			seen[name] = true
+			ret = append(ret, prefix+name)
+		}
+		for _, attr := range deprecatedAttributes(nested, blockType.Block, prefix+name+".") {
+			if !seen[attr] {
+				seen[attr] = true
+				ret = append(ret, attr)
+			}
+		}
+	}
+	return ret
+}
+
+// moduleProviders returns the providers of the resources of the module.
+func moduleProviders(mod *configs.Module) []addrs.Provider {
+	var ret []addrs.Provider
+	seen := map[addrs.Provider]bool{}
+	for _, r := range moduleResources(mod) {
+		if !seen[r.Provider] {
+			seen[r.Provider] = true
+			ret = append(ret, r.Provider)
+		}
+	}
+	return ret
+}
+
+// installedProviderSchemas loads the schemas of the latest installed version
+// of each of the given providers, as installed for the working dir or in the
+// plugin cache dir. Providers which are not installed are reported as
+// warnings.
+func installedProviderSchemas(providerAddrs []addrs.Provider, workingDir string) (*terraform.Schemas, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	dirs := providerDirs(workingDir, "")
+	cachedProviders := map[addrs.Provider]*providercache.CachedProvider{}
+	for _, addr := range providerAddrs {
+		cached := findCachedProvider(addr, getproviders.UnspecifiedVersion, dirs)
+		if cached == nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Warning,
+				"Provider not installed",
+				fmt.Sprintf("Provider %s is not available locally, so its schema can't be loaded. Install it by running \"terraform init\".", addr),
+			))
+			continue
+		}
+		cachedProviders[addr] = cached
+	}
+	schemas, schemaDiags := loadProviderSchemas(cachedProviders)
+	return schemas, diags.Append(schemaDiags)
+}
+
+//export EvaluateLocals
+func EvaluateLocals(cPath *C.char, cVarsJSON *C.char) (cLocals *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	ctx, ctxDiags := evalContext(mod, C.GoString(cVarsJSON))
+	diags = diags.Append(ctxDiags)
+	if ctx == nil {
+		return toCResult(nil, diags, nil)
+	}
+	locals := map[string]*StaticValue{}
+	for name, val := range ctx.Variables["local"].AsValueMap() {
+		locals[name] = newStaticValue(val)
+	}
+	return toCResult(locals, diags, nil)
+}
+
+// StaticValue is a statically evaluated value.
+type StaticValue struct {
+	// Value is the JSON representation of the value, which is null if the
+	// value is not wholly known.
+	Value json.RawMessage
+	Known bool
+}
+
+func newStaticValue(val cty.Value) *StaticValue {
+	ret := &StaticValue{Value: json.RawMessage("null")}
+	switch {
+	case !val.IsWhollyKnown():
+		// Unknown values have no JSON representation.
+	case val.IsNull():
+		ret.Known = true
+	default:
+		if raw, err := ctyjson.Marshal(val, val.Type()); err == nil {
+			ret.Value = raw
+			ret.Known = true
+		}
+	}
+	return ret
+}
+
+//export EvalExpression
+func EvalExpression(cExpr *C.char, cVarsJSON *C.char) (cValue *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	ctx, diags := evalContext(nil, C.GoString(cVarsJSON))
+	if diags.HasErrors() {
+		return toCResult(nil, diags, nil)
+	}
+	val, valDiags := evalExpression(C.GoString(cExpr), ctx)
+	return toCResult(val, diags.Append(valDiags), nil)
+}
+
+// evalExpression evaluates an expression in the native syntax with the given
+// context. References to anything not in the context, such as resource
+// attributes, are reported as errors. The value is nil if there are any
+// errors.
+func evalExpression(expr string, ctx *hcl.EvalContext) (*StaticValue, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	parsed, parseDiags := hclsyntax.ParseExpression([]byte(expr), "<expression>", hcl.InitialPos)
+	diags = diags.Append(parseDiags)
+	if parseDiags.HasErrors() {
+		return nil, diags
+	}
+	val, valDiags := parsed.Value(ctx)
+	diags = diags.Append(valDiags)
+	if valDiags.HasErrors() {
+		return nil, diags
+	}
+	return newStaticValue(val), diags
+}
+
+//export ConsoleEvalBatch
+func ConsoleEvalBatch(cPath *C.char, cExprsJSON *C.char, cVarsJSON *C.char) (cResults *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	var exprs []string
+	if err := json.Unmarshal([]byte(C.GoString(cExprsJSON)), &exprs); err != nil {
+		return toCResult(nil, nil, fmt.Errorf("invalid expressions JSON: %s", err))
+	}
+
+	var mod *configs.Module
+	var diags tfdiags.Diagnostics
+	if path := C.GoString(cPath); path != "" {
+		var err error
+		mod, diags, err = loadModule(path)
+		if err != nil {
+			return toCResult(nil, nil, err)
+		}
+	}
+	ctx, ctxDiags := evalContext(mod, C.GoString(cVarsJSON))
+	diags = diags.Append(ctxDiags)
+	if ctx == nil {
+		return toCResult(nil, diags, nil)
+	}
+
+	results := make([]*ExprResult, 0, len(exprs))
+	for _, expr := range exprs {
+		val, valDiags := evalExpression(expr, ctx)
+		results = append(results, &ExprResult{
+			Expression:  expr,
+			Value:       val,
+			Diagnostics: convertDiagnostics(valDiags),
+		})
+	}
+	return toCResult(results, diags, nil)
+}
+
+// ExprResult is the result of evaluating one of the expressions of a batch.
+type ExprResult struct {
+	Expression string
+	// Value is nil if the expression could not be evaluated, in which case
+	// Diagnostics has the errors.
+	Value       *StaticValue
+	Diagnostics []*Diagnostic
+}
+
+//export DynamicResources
+func DynamicResources(cPath *C.char) (cResources *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, sources, diags, err := loadModuleWithSources(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	return toCResult(dynamicResources(mod, sources), diags, nil)
+}
+
+// DynamicResource is a resource whose number of instances is set by the count
+// or for_each meta-argument.
+type DynamicResource struct {
+	Address string
+	// MetaArgument is either "count" or "for_each".
+	MetaArgument string
+	// Expression is the source code of the meta-argument expression.
+	Expression string
+	DeclRange  hcl.Range
+}
+
+// dynamicResources finds the managed and data resources of the module using
+// count or for_each, sorted by address.
+func dynamicResources(mod *configs.Module, sources map[string][]byte) []*DynamicResource {
+	ret := []*DynamicResource{}
+	for _, r := range moduleResources(mod) {
+		var metaArg string
+		var expr hcl.Expression
+		switch {
+		case r.Count != nil:
+			metaArg, expr = "count", r.Count
+		case r.ForEach != nil:
+			metaArg, expr = "for_each", r.ForEach
+		default:
+			continue
+		}
+		ret = append(ret, &DynamicResource{
+			Address:      r.Addr().String(),
+			MetaArgument: metaArg,
+			Expression:   exprString(expr, sources),
+			DeclRange:    r.DeclRange,
+		})
+	}
+	return ret
+}
+
+//export CheckOutputReferences
+func CheckOutputReferences(cPath *C.char) (cReferences *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	refs, refDiags := checkOutputReferences(mod)
+	return toCResult(refs, diags.Append(refDiags), nil)
+}
+
+// UndeclaredReference is a reference of an output to an object which is not
+// declared in the module, where Summary tells the kind of the object, such as
+// "Reference to undeclared resource".
+type UndeclaredReference struct {
+	Output    string
+	Reference string
+	Summary   string
+	DeclRange hcl.Range
+}
+
+// checkOutputReferences finds the references of the outputs of the module to
+// resources, input variables, local values and module calls which are not
+// declared in the module, sorted by output name and then position. Each one is
+// also reported as an error in diags.
+func checkOutputReferences(mod *configs.Module) ([]*UndeclaredReference, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	names := make([]string, 0, len(mod.Outputs))
+	for name := range mod.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := []*UndeclaredReference{}
+	for _, name := range names {
+		refs, refDiags := lang.ReferencesInExpr(mod.Outputs[name].Expr)
+		diags = diags.Append(refDiags)
+		for _, ref := range refs {
+			var summary string
+			switch subject := ref.Subject.(type) {
+			case addrs.Resource:
+				if mod.ResourceByAddr(subject) == nil {
+					summary = "Reference to undeclared resource"
+				}
+			case addrs.ResourceInstance:
+				if mod.ResourceByAddr(subject.Resource) == nil {
+					summary = "Reference to undeclared resource"
+				}
+			case addrs.InputVariable:
+				if _, ok := mod.Variables[subject.Name]; !ok {
+					summary = "Reference to undeclared input variable"
+				}
+			case addrs.LocalValue:
+				if _, ok := mod.Locals[subject.Name]; !ok {
+					summary = "Reference to undeclared local value"
+				}
+			case addrs.ModuleCallInstance:
+				if _, ok := mod.ModuleCalls[subject.Call.Name]; !ok {
+					summary = "Reference to undeclared module"
+				}
+			case addrs.ModuleCallInstanceOutput:
+				if _, ok := mod.ModuleCalls[subject.Call.Call.Name]; !ok {
+					summary = "Reference to undeclared module"
+				}
+			}
+			if summary == "" {
+				continue
+			}
+
+			rng := ref.SourceRange.ToHCL()
+			ret = append(ret, &UndeclaredReference{
+				Output:    name,
+				Reference: ref.Subject.String(),
+				Summary:   summary,
+				DeclRange: rng,
+			})
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  summary,
+				Detail:   fmt.Sprintf("Output %q refers to %s, which is not declared in the module.", name, ref.Subject),
+				Subject:  rng.Ptr(),
+			})
+		}
+	}
+	return ret, diags
+}
+
+//export UsedProviderFunctions
+func UsedProviderFunctions(cPath *C.char) (cCalls *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	// The diagnostics of the module are left out, since the embedded
+	// Terraform may not support provider-defined functions and so fail to
+	// parse the calls.
+	_, sources, _, err := loadModuleWithSources(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	calls, diags := usedProviderFunctions(sources)
+	return toCResult(calls, diags, nil)
+}
+
+// ProviderFunctionCall is a call of a provider-defined function, such as
+// "provider::aws::arn_parse", which is supported from Terraform v1.8.
+type ProviderFunctionCall struct {
+	Name      string
+	Provider  string
+	Function  string
+	DeclRange hcl.Range
+}
+
+// providerFunctionTokens are the tokens of a provider-defined function call
+// up to its opening parenthesis, where the empty strings stand for the
+// provider local name and the function name. Each "::" is two colon tokens
+// for the HCL versions which don't support these calls.
+var providerFunctionTokens = []struct {
+	Type  hclsyntax.TokenType
+	Bytes string
+}{
+	{hclsyntax.TokenIdent, "provider"},
+	{hclsyntax.TokenColon, ":"},
+	{hclsyntax.TokenColon, ":"},
+	{hclsyntax.TokenIdent, ""},
+	{hclsyntax.TokenColon, ":"},
+	{hclsyntax.TokenColon, ":"},
+	{hclsyntax.TokenIdent, ""},
+	{hclsyntax.TokenOParen, "("},
+}
+
+// usedProviderFunctions finds the provider-defined function calls in the
+// native syntax files of a module, sorted by file name and then position.
+// The files are scanned rather than parsed, so that the calls are found
+// whatever the version of the embedded Terraform.
+func usedProviderFunctions(sources map[string][]byte) ([]*ProviderFunctionCall, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	filenames := make([]string, 0, len(sources))
+	for filename := range sources {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	ret := []*ProviderFunctionCall{}
+	for _, filename := range filenames {
+		if !strings.HasSuffix(filename, ".tf") {
+			continue
+		}
+		tokens, lexDiags := hclsyntax.LexConfig(sources[filename], filename, hcl.InitialPos)
+		diags = diags.Append(lexDiags)
+	Tokens:
+		for i := 0; i+len(providerFunctionTokens) <= len(tokens); i++ {
+			for j, want := range providerFunctionTokens {
+				token := tokens[i+j]
+				if token.Type != want.Type || (want.Bytes != "" && string(token.Bytes) != want.Bytes) {
+					continue Tokens
+				}
+			}
+			provider, function := string(tokens[i+3].Bytes), string(tokens[i+6].Bytes)
+			ret = append(ret, &ProviderFunctionCall{
+				Name:      fmt.Sprintf("provider::%s::%s", provider, function),
+				Provider:  provider,
+				Function:  function,
+				DeclRange: hcl.RangeBetween(tokens[i].Range, tokens[i+6].Range),
+			})
+		}
+	}
+	return ret, diags
+}
+
+//export CheckRemoteStateReferences
+func CheckRemoteStateReferences(cPath *C.char) (cReferences *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+
+	refs, diags, err := checkRemoteStateReferences(C.GoString(cPath))
+	return toCResult(refs, diags, err)
 }
 
-func convertModule(mod *configs.Module) *ShortModule {
-	shortMod := &ShortModule{
-		SourceDir:              mod.SourceDir,
-		CoreVersionConstraints: mod.CoreVersionConstraints,
-		ActiveExperiments:      mod.ActiveExperiments,
-		Backend:                mod.Backend,
-		CloudConfig:            mod.CloudConfig,
-		ProviderRequirements:   mod.ProviderRequirements,
-		Variables:              mod.Variables,
-		Locals:                 mod.Locals,
-		Outputs:                mod.Outputs,
-		ModuleCalls:            mod.ModuleCalls,
-		ManagedResources:       mod.ManagedResources,
-		DataResources:          mod.DataResources,
-		Moved:                  mod.Moved,
+// RemoteStateReference is a reference to an output of the state read by a
+// terraform_remote_state data source, such as
+// data.terraform_remote_state.network.outputs.vpc_id, where Exists tells
+// whether the state or the defaults of the data source provide the output.
+type RemoteStateReference struct {
+	DataSource string
+	Output     string
+	Exists     bool
+	DeclRange  hcl.Range
+}
+
+// checkRemoteStateReferences reads the remote states of the
+// terraform_remote_state data sources of the module in the given directory,
+// which is also the working dir relative paths of their config are resolved
+// against, and checks the references of the module to their outputs, sorted by
+// position. Missing outputs are also reported as errors in diags, while data
+// sources whose state can't be read, such as when their config depends on
+// resources or they use count or for_each, are reported as warnings and their
+// references left out.
+func checkRemoteStateReferences(path string) ([]*RemoteStateReference, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	restore, err := chdir(path)
+	if err != nil {
+		return nil, diags, err
 	}
-	return shortMod
+	defer restore()
+
+	mod, modDiags, err := loadModule(".")
+	if err != nil {
+		return nil, diags, err
+	}
+	diags = diags.Append(modDiags)
+	ctx, ctxDiags := evalContext(mod, "")
+	diags = diags.Append(ctxDiags)
+
+	config, configDiags := cliconfig.LoadConfig()
+	diags = diags.Append(configDiags)
+	backendInit.Init(newServices(config))
+
+	outputs := map[string]cty.Value{}
+	for _, r := range mod.DataResources {
+		if r.Type != "terraform_remote_state" || !r.Provider.IsBuiltIn() {
+			continue
+		}
+		val, err := readRemoteStateOutputs(r, ctx)
+		if err != nil {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Remote state not checked",
+				Detail:   fmt.Sprintf("The state of %s could not be read: %s", r.Addr(), err),
+				Subject:  r.DeclRange.Ptr(),
+			})
+			continue
+		}
+		outputs[r.Name] = val
+	}
+
+	ret := []*RemoteStateReference{}
+	for _, traversal := range moduleTraversals(mod) {
+		name, output, ok := remoteStateOutput(traversal)
+		if !ok {
+			continue
+		}
+		val, read := outputs[name]
+		if !read {
+			continue
+		}
+		ref := &RemoteStateReference{
+			DataSource: "data.terraform_remote_state." + name,
+			Output:     output,
+			DeclRange:  traversal.SourceRange(),
+		}
+		if !val.IsNull() && val.Type().IsObjectType() {
+			ref.Exists = val.Type().HasAttribute(output)
+		}
+		if !ref.Exists {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Reference to undeclared remote state output",
+				Detail:   fmt.Sprintf("The state read by %s has no output named %q.", ref.DataSource, output),
+				Subject:  ref.DeclRange.Ptr(),
+			})
+		}
+		ret = append(ret, ref)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i].DeclRange, ret[j].DeclRange
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return ret, diags, nil
 }
 
-//export ConfigLoadConfigDir
-func ConfigLoadConfigDir(cPath *C.char) (cMod *C.char, cDiags *C.char, cError *C.char) {
+// readRemoteStateOutputs reads the outputs of the state of the given
+// terraform_remote_state data source with the builtin terraform provider,
+// merged with its defaults, evaluating its config with the given context.
+func readRemoteStateOutputs(r *configs.Resource, ctx *hcl.EvalContext) (cty.Value, error) {
+	if r.Count != nil || r.ForEach != nil {
+		return cty.NilVal, fmt.Errorf("data sources with count or for_each are not supported")
+	}
+	provider := terraformProvider.NewProvider()
+	schema := provider.GetProviderSchema().DataSources[r.Type]
+	config, hclDiags := hcldec.Decode(r.Config, schema.Block.DecoderSpec(), ctx)
+	if hclDiags.HasErrors() {
+		return cty.NilVal, hclDiags
+	}
+	if !config.IsWhollyKnown() {
+		return cty.NilVal, fmt.Errorf("its config depends on values only known during a plan")
+	}
+	resp := provider.ReadDataSource(providers.ReadDataSourceRequest{
+		TypeName: r.Type,
+		Config:   config,
+	})
+	if resp.Diagnostics.HasErrors() {
+		return cty.NilVal, resp.Diagnostics.Err()
+	}
+	return resp.State.GetAttr("outputs"), nil
+}
+
+// remoteStateOutput returns the name of the terraform_remote_state data
+// source and the name of its output the traversal refers to, if any.
+func remoteStateOutput(traversal hcl.Traversal) (name string, output string, ok bool) {
+	if len(traversal) < 5 || traversal.RootName() != "data" {
+		return "", "", false
+	}
+	steps := make([]string, 0, 3)
+	for _, step := range traversal[1:4] {
+		attr, isAttr := step.(hcl.TraverseAttr)
+		if !isAttr {
+			return "", "", false
+		}
+		steps = append(steps, attr.Name)
+	}
+	if steps[0] != "terraform_remote_state" || steps[2] != "outputs" {
+		return "", "", false
+	}
+	switch step := traversal[4].(type) {
+	case hcl.TraverseAttr:
+		return steps[1], step.Name, true
+	case hcl.TraverseIndex:
+		if step.Key.Type() == cty.String && step.Key.IsKnown() && !step.Key.IsNull() {
+			return steps[1], step.Key.AsString(), true
+		}
+	}
+	return "", "", false
+}
+
+// moduleTraversals returns the traversals of the expressions of the resources,
+// local values, outputs, module calls and provider configurations of the
+// module. Like bodyTraversals, only native syntax bodies are looked into.
+func moduleTraversals(mod *configs.Module) []hcl.Traversal {
+	var ret []hcl.Traversal
+	addExprs := func(exprs ...hcl.Expression) {
+		for _, expr := range exprs {
+			if expr != nil {
+				ret = append(ret, expr.Variables()...)
+			}
+		}
+	}
+	for _, r := range moduleResources(mod) {
+		ret = append(ret, bodyTraversals(r.Config)...)
+		addExprs(r.Count, r.ForEach)
+	}
+	for _, l := range mod.Locals {
+		addExprs(l.Expr)
+	}
+	for _, o := range mod.Outputs {
+		addExprs(o.Expr)
+	}
+	for _, mc := range mod.ModuleCalls {
+		ret = append(ret, bodyTraversals(mc.Config)...)
+		addExprs(mc.Count, mc.ForEach)
+	}
+	for _, p := range mod.ProviderConfigs {
+		ret = append(ret, bodyTraversals(p.Config)...)
+	}
+	return ret
+}
+
+//export VariablesByRequirement
+func VariablesByRequirement(cPath *C.char) (cGroups *C.char, cDiags *C.char, cError *C.char) {
 	defer func() {
 		recover()
 	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
+	if err != nil {
+		return toCResult(nil, nil, err)
+	}
+	return toCResult(variablesByRequirement(mod), diags, nil)
+}
+
+// VariableGroups are the names of the input variables of a module, split
+// into the Required ones, which have no default, and the Optional ones.
+type VariableGroups struct {
+	Required []string
+	Optional []string
+}
+
+// variablesByRequirement groups the input variables of the module, sorted by
+// name. A variable with a null default is optional, as it is for Terraform.
+func variablesByRequirement(mod *configs.Module) *VariableGroups {
+	ret := &VariableGroups{
+		Required: []string{},
+		Optional: []string{},
+	}
+	for name, v := range mod.Variables {
+		if v.Default == cty.NilVal {
+			ret.Required = append(ret.Required, name)
+		} else {
+			ret.Optional = append(ret.Optional, name)
+		}
+	}
+	sort.Strings(ret.Required)
+	sort.Strings(ret.Optional)
+	return ret
+}
+
+//export FindUnlockedProviders
+func FindUnlockedProviders(cPath *C.char) (cProviders *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
 
-	parser := configs.NewParser(nil)
 	path := C.GoString(cPath)
-	mod, diags := parser.LoadConfigDir(path)
-	modBytes, err := json.Marshal(convertModule(mod))
+	mod, diags, err := loadModule(path)
 	if err != nil {
-		cMod = C.CString("")
-		cDiags = C.CString("")
-		cError = C.CString(err.Error())
-		return cMod, cDiags, cError
+		return toCResult(nil, nil, err)
 	}
-	diagsBytes, err := json.Marshal(diags)
+	lock, lockDiags := loadDependencyLock(path)
+	diags = diags.Append(lockDiags)
+	return toCResult(findUnlockedProviders(mod, lock), diags, nil)
+}
+
+// UnlockedProvider is a provider used by resources of a module which is not
+// selected in its dependency lock file.
+type UnlockedProvider struct {
+	Provider  string
+	Resources []string
+}
+
+// findUnlockedProviders finds the providers of the resources of the module
+// which are missing from the given dependency lock file, sorted by address,
+// with their resources sorted by address. Built-in providers are never
+// locked, so they are left out.
+func findUnlockedProviders(mod *configs.Module, lock *DependencyLock) []*UnlockedProvider {
+	locked := make(map[string]bool, len(lock.Providers))
+	for _, p := range lock.Providers {
+		locked[p.Source] = true
+	}
+
+	unlocked := map[string]*UnlockedProvider{}
+	for _, r := range moduleResources(mod) {
+		if r.Provider.IsZero() || r.Provider.IsBuiltIn() || locked[r.Provider.String()] {
+			continue
+		}
+		provider := r.Provider.String()
+		if unlocked[provider] == nil {
+			unlocked[provider] = &UnlockedProvider{Provider: provider}
+		}
+		unlocked[provider].Resources = append(unlocked[provider].Resources, r.Addr().String())
+	}
+
+	ret := make([]*UnlockedProvider, 0, len(unlocked))
+	for _, p := range unlocked {
+		sort.Strings(p.Resources)
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Provider < ret[j].Provider
+	})
+	return ret
+}
+
+//export TotalInstanceCount
+func TotalInstanceCount(cPath *C.char, cVarsJSON *C.char) (cCount *C.char, cDiags *C.char, cError *C.char) {
+	defer func() {
+		recover()
+	}()
+	defer rlockCwd()()
+
+	mod, diags, err := loadModule(C.GoString(cPath))
 	if err != nil {
-		cMod = C.CString(string(modBytes))
-		cDiags = C.CString("")
-		cError = C.CString(err.Error())
-		return cMod, cDiags, cError
+		return toCResult(nil, nil, err)
 	}
-	cMod = C.CString(string(modBytes))
-	cDiags = C.CString(string(diagsBytes))
-	cError = C.CString("")
-	return cMod, cDiags, cError
+	ctx, ctxDiags := evalContext(mod, C.GoString(cVarsJSON))
+	diags = diags.Append(ctxDiags)
+	return toCResult(totalInstanceCount(mod, ctx), diags, nil)
+}
+
+// InstanceCount is the number of resource instances a module declares after
+// the expansion of count and for_each.
+type InstanceCount struct {
+	Count int
+	// Unknown are the addresses of the resources whose number of instances
+	// can't be determined statically, which are not included in Count.
+	Unknown []string
+}
+
+// totalInstanceCount counts the instances of the managed and data resources
+// declared by the module, evaluating count and for_each with the given
+// context. Resources of child modules are not included.
+func totalInstanceCount(mod *configs.Module, ctx *hcl.EvalContext) *InstanceCount {
+	ret := &InstanceCount{Unknown: []string{}}
+	for _, r := range moduleResources(mod) {
+		n, known := resourceInstanceCount(r, ctx)
+		if !known {
+			ret.Unknown = append(ret.Unknown, r.Addr().String())
+			continue
+		}
+		ret.Count += n
+	}
+	return ret
+}
+
+// resourceInstanceCount returns the number of instances of the resource, or
+// false if it depends on values that are unknown in the given context.
+func resourceInstanceCount(r *configs.Resource, ctx *hcl.EvalContext) (int, bool) {
+	switch {
+	case r.Count != nil:
+		val, diags := r.Count.Value(ctx)
+		if diags.HasErrors() || !val.IsWhollyKnown() || val.IsNull() {
+			return 0, false
+		}
+		val, err := convert.Convert(val, cty.Number)
+		if err != nil {
+			return 0, false
+		}
+		var count int
+		if err := gocty.FromCtyValue(val, &count); err != nil || count < 0 {
+			return 0, false
+		}
+		return count, true
+	case r.ForEach != nil:
+		val, diags := r.ForEach.Value(ctx)
+		if diags.HasErrors() || !val.IsWhollyKnown() || val.IsNull() {
+			return 0, false
+		}
+		ty := val.Type()
+		switch {
+		case ty.IsObjectType():
+			return len(ty.AttributeTypes()), true
+		case ty.IsMapType() || ty.IsSetType():
+			return val.LengthInt(), true
+		default:
+			return 0, false
+		}
+	default:
+		return 1, true
+	}
+}
+
+// moduleResources returns the managed and data resources of the module,
+// sorted by address.
+func moduleResources(mod *configs.Module) []*configs.Resource {
+	resources := make([]*configs.Resource, 0, len(mod.ManagedResources)+len(mod.DataResources))
+	for _, r := range mod.ManagedResources {
+		resources = append(resources, r)
+	}
+	for _, r := range mod.DataResources {
+		resources = append(resources, r)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Addr().String() < resources[j].Addr().String()
+	})
+	return resources
+}
+
+// loadModule loads the module in the given directory. Unlike the diagnostics
+// of the config, an error is returned if the directory could not be read.
+func loadModule(path string) (*configs.Module, tfdiags.Diagnostics, error) {
+	mod, _, diags, err := loadModuleWithSources(path)
+	return mod, diags, err
+}
+
+// loadModuleWithSources is like loadModule, also returning the source code
+// of the loaded files keyed by file name.
+func loadModuleWithSources(path string) (*configs.Module, map[string][]byte, tfdiags.Diagnostics, error) {
+	var diags tfdiags.Diagnostics
+
+	parser := configs.NewParser(nil)
+	mod, hclDiags := parser.LoadConfigDir(path)
+	diags = diags.Append(hclDiags)
+	if mod == nil {
+		return nil, nil, diags, fmt.Errorf("the given directory %q does not exist at all or could not be opened for some reason", path)
+	}
+	return mod, parser.Sources(), diags, nil
+}
+
+// exprString returns the value of a static string or primitive expression,
+// or else the source code of the expression.
+func exprString(expr hcl.Expression, sources map[string][]byte) string {
+	val, diags := expr.Value(nil)
+	if !diags.HasErrors() && val.IsWhollyKnown() && !val.IsNull() && val.Type().IsPrimitiveType() {
+		if str, err := convert.Convert(val, cty.String); err == nil {
+			return str.AsString()
+		}
+	}
+	rng := expr.Range()
+	src, ok := sources[rng.Filename]
+	if !ok || rng.End.Byte > len(src) || rng.Start.Byte > rng.End.Byte {
+		return ""
+	}
+	return string(rng.SliceBytes(src))
+}
+
+// evalContext builds an HCL evaluation context to statically evaluate the
+// expressions of the given module, with the standard functions of the
+// language and the input variables set from the given JSON object. Declared
+// variables that are not set take their default, or else are unknown.
+//
+// If mod is nil, the context has a variable for each one in the JSON object.
+func evalContext(mod *configs.Module, varsJSON string) (*hcl.EvalContext, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	rawVars := map[string]json.RawMessage{}
+	if varsJSON != "" {
+		if err := json.Unmarshal([]byte(varsJSON), &rawVars); err != nil {
+			return nil, diags.Append(fmt.Errorf("invalid variables JSON: %s", err))
+		}
+	}
+
+	baseDir := "."
+	if mod != nil {
+		baseDir = mod.SourceDir
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = baseDir
+	}
+	vars := map[string]cty.Value{}
+	for name, raw := range rawVars {
+		ty, err := ctyjson.ImpliedType(raw)
+		if err == nil {
+			vars[name], err = ctyjson.Unmarshal(raw, ty)
+		}
+		if err != nil {
+			diags = diags.Append(fmt.Errorf("invalid value for variable %q: %s", name, err))
+			vars[name] = cty.DynamicVal
+		}
+	}
+	if mod != nil {
+		for name := range vars {
+			if _, declared := mod.Variables[name]; !declared {
+				diags = diags.Append(tfdiags.Sourceless(
+					tfdiags.Warning,
+					"Value for undeclared variable",
+					fmt.Sprintf("The module does not declare a variable named %q, so its value is ignored.", name),
+				))
+				delete(vars, name)
+			}
+		}
+		for name, v := range mod.Variables {
+			val, given := vars[name]
+			switch {
+			case given:
+				converted, err := convert.Convert(val, v.Type)
+				if err != nil {
+					diags = diags.Append(fmt.Errorf("invalid value for variable %q: %s", name, err))
+					converted = cty.UnknownVal(v.Type)
+				}
+				vars[name] = converted
+			case v.Default != cty.NilVal:
+				vars[name] = v.Default
+			default:
+				vars[name] = cty.UnknownVal(v.Type)
+			}
+		}
+	}
+
+	scope := &lang.Scope{BaseDir: baseDir}
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(vars),
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"module": cty.StringVal(baseDir),
+				"root":   cty.StringVal(baseDir),
+				"cwd":    cty.StringVal(cwd),
+			}),
+			"terraform": cty.ObjectVal(map[string]cty.Value{
+				"workspace": cty.UnknownVal(cty.String),
+			}),
+		},
+		Functions: scope.Functions(),
+	}
+	if mod != nil {
+		locals, localDiags := evalLocals(mod, ctx)
+		diags = diags.Append(localDiags)
+		ctx.Variables["local"] = cty.ObjectVal(locals)
+	}
+	return ctx, diags
+}
+
+// evalLocals evaluates the local values of the module with the given context.
+// Locals are evaluated after the locals they refer to, and the ones which
+// depend on values that can't be evaluated statically, such as resource
+// attributes, or which are part of a reference cycle are unknown.
+func evalLocals(mod *configs.Module, ctx *hcl.EvalContext) (map[string]cty.Value, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	locals := make(map[string]cty.Value, len(mod.Locals))
+	pending := make(map[string]*configs.Local, len(mod.Locals))
+	for name, l := range mod.Locals {
+		pending[name] = l
+	}
+
+	localCtx := ctx.NewChild()
+	for len(pending) > 0 {
+		localCtx.Variables = map[string]cty.Value{"local": cty.ObjectVal(locals)}
+		progress := false
+		for name, l := range pending {
+			if !localDepsEvaluated(l.Expr, locals, pending) {
+				continue
+			}
+			delete(pending, name)
+			progress = true
+
+			val, valDiags := l.Expr.Value(localCtx)
+			if valDiags.HasErrors() {
+				if refersOnlyTo(l.Expr, ctx) {
+					diags = diags.Append(valDiags)
+				}
+				val = cty.DynamicVal
+			}
+			locals[name] = val
+		}
+		if progress {
+			continue
+		}
+
+		// The remaining locals refer to each other in a cycle.
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Cycle in local values",
+				Detail:   fmt.Sprintf("The local value %q depends on itself through other local values.", name),
+				Subject:  pending[name].DeclRange.Ptr(),
+			})
+			locals[name] = cty.DynamicVal
+		}
+		break
+	}
+	return locals, diags
+}
+
+// localDepsEvaluated returns whether all of the locals referred to by the
+// expression are evaluated. A local which is neither evaluated nor pending
+// doesn't exist, which is reported when evaluating the expression.
+func localDepsEvaluated(expr hcl.Expression, locals map[string]cty.Value, pending map[string]*configs.Local) bool {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "local" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			continue
+		}
+		if _, evaluated := locals[attr.Name]; evaluated {
+			continue
+		}
+		if _, ok := pending[attr.Name]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// refersOnlyTo returns whether all of the variables the expression refers to
+// are in the given context, or else its value can't be evaluated statically.
+func refersOnlyTo(expr hcl.Expression, ctx *hcl.EvalContext) bool {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() == "local" {
+			continue
+		}
+		if _, ok := ctx.Variables[traversal.RootName()]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 // **********************************************
 // Utils
 // **********************************************
 
+// goArgs converts the C array of CLI args to Go.
+func goArgs(cArgc C.int, cArgv **C.char) []string {
+	argc := int(cArgc)
+	slice := unsafe.Slice(cArgv, argc)
+	args := make([]string, 0, argc)
+	for _, s := range slice {
+		args = append(args, C.GoString(s))
+	}
+	return args
+}
+
+// toCResult converts the result of an export and its diagnostics into the
+// (result, diags, error) C strings returned by the exports. The result and
+// the diagnostics are both marshaled as JSON.
+func toCResult(result interface{}, diags tfdiags.Diagnostics, err error) (*C.char, *C.char, *C.char) {
+	return toCStrings(result, convertDiagnostics(diags), err)
+}
+
+// toCStrings is like toCResult, for exports returning diagnostics in a form
+// other than tfdiags.Diagnostics.
+func toCStrings(result interface{}, diags interface{}, err error) (*C.char, *C.char, *C.char) {
+	if err != nil {
+		return C.CString(""), C.CString(""), C.CString(err.Error())
+	}
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return C.CString(""), C.CString(""), C.CString(err.Error())
+	}
+	diagsBytes, err := json.Marshal(diags)
+	if err != nil {
+		return C.CString(string(resultBytes)), C.CString(""), C.CString(err.Error())
+	}
+	return C.CString(string(resultBytes)), C.CString(string(diagsBytes)), C.CString("")
+}
+
+// Diagnostic is the JSON representation of the diagnostics returned by the
+// exports. Unlike the diagnostics of Terraform itself, its structure is kept
+// stable across Terraform versions.
+type Diagnostic struct {
+	// Severity is either "error" or "warning".
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+
+	// Filename and the start and end lines and columns locate the subject
+	// of the diagnostic, and are empty if it has no subject.
+	Filename    string `json:"filename"`
+	StartLine   int    `json:"start_line"`
+	StartColumn int    `json:"start_column"`
+	EndLine     int    `json:"end_line"`
+	EndColumn   int    `json:"end_column"`
+
+	// Subject is the source range the diagnostic is about, including its
+	// byte offsets, or nil if it has none.
+	Subject *DiagnosticRange `json:"subject"`
+}
+
+// DiagnosticRange is a range in a source file.
+type DiagnosticRange struct {
+	Filename string        `json:"filename"`
+	Start    DiagnosticPos `json:"start"`
+	End      DiagnosticPos `json:"end"`
+}
+
+// DiagnosticPos is a position in a source file. Line and Column are 1-based
+// and Column counts unicode characters, while Byte is a 0-based byte offset.
+type DiagnosticPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// newDiagnostic converts a diagnostic to its JSON representation.
+func newDiagnostic(diag tfdiags.Diagnostic) *Diagnostic {
+	desc := diag.Description()
+	ret := &Diagnostic{
+		Severity: "error",
+		Summary:  desc.Summary,
+		Detail:   desc.Detail,
+	}
+	if diag.Severity() == tfdiags.Warning {
+		ret.Severity = "warning"
+	}
+	if subject := diag.Source().Subject; subject != nil {
+		ret.Filename = subject.Filename
+		ret.StartLine = subject.Start.Line
+		ret.StartColumn = subject.Start.Column
+		ret.EndLine = subject.End.Line
+		ret.EndColumn = subject.End.Column
+		ret.Subject = &DiagnosticRange{
+			Filename: subject.Filename,
+			Start:    DiagnosticPos{Line: subject.Start.Line, Column: subject.Start.Column, Byte: subject.Start.Byte},
+			End:      DiagnosticPos{Line: subject.End.Line, Column: subject.End.Column, Byte: subject.End.Byte},
+		}
+	}
+	return ret
+}
+
+// convertDiagnostics converts diagnostics to their JSON representation.
+func convertDiagnostics(diags tfdiags.Diagnostics) []*Diagnostic {
+	jsonDiags := make([]*Diagnostic, 0, len(diags))
+	for _, diag := range diags {
+		jsonDiags = append(jsonDiags, newDiagnostic(diag))
+	}
+	return jsonDiags
+}
+
+// convertHCLDiagnostics is like convertDiagnostics, for HCL diagnostics.
+func convertHCLDiagnostics(diags hcl.Diagnostics) []*Diagnostic {
+	var tfDiags tfdiags.Diagnostics
+	return convertDiagnostics(tfDiags.Append(diags))
+}
+
+// dedupeDiagnostics removes the diagnostics with the same severity, summary
+// and subject range as an earlier one.
+func dedupeDiagnostics(diags hcl.Diagnostics) hcl.Diagnostics {
+	seen := map[string]struct{}{}
+	ret := make(hcl.Diagnostics, 0, len(diags))
+	for _, diag := range diags {
+		key := fmt.Sprintf("%d\x00%s", diag.Severity, diag.Summary)
+		if rng := diag.Subject; rng != nil {
+			key += fmt.Sprintf("\x00%s:%d-%d", rng.Filename, rng.Start.Byte, rng.End.Byte)
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		ret = append(ret, diag)
+	}
+	return ret
+}
+
+// traversalString renders a traversal the way it is written in the config,
+// such as aws_instance.web or module.foo[0].
+func traversalString(traversal hcl.Traversal) string {
+	var buf strings.Builder
+	for _, step := range traversal {
+		switch ts := step.(type) {
+		case hcl.TraverseRoot:
+			buf.WriteString(ts.Name)
+		case hcl.TraverseAttr:
+			buf.WriteString(".")
+			buf.WriteString(ts.Name)
+		case hcl.TraverseIndex:
+			buf.WriteString("[")
+			switch {
+			case !ts.Key.IsKnown() || ts.Key.IsNull():
+				buf.WriteString("*")
+			case ts.Key.Type() == cty.String:
+				buf.WriteString(strconv.Quote(ts.Key.AsString()))
+			case ts.Key.Type() == cty.Number:
+				buf.WriteString(ts.Key.AsBigFloat().Text('f', -1))
+			}
+			buf.WriteString("]")
+		case hcl.TraverseSplat:
+			buf.WriteString("[*]")
+		}
+	}
+	return buf.String()
+}
+
+//export FreeString
+func FreeString(cString *C.char) {
+	// Do nothing for nil, so that the binding can free the strings of a result
+	// defensively on error paths, where some of them are never set.
+	if cString == nil {
+		return
+	}
+	C.free(unsafe.Pointer(cString))
+}
+
+// Free is kept for bindings built against earlier versions.
+//
+// Deprecated: Use FreeString instead.
+//
 //export Free
 func Free(cString *int) {
-	C.free(unsafe.Pointer(cString))
+	FreeString((*C.char)(unsafe.Pointer(cString)))
 }
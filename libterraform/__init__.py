@@ -1,5 +1,5 @@
 import os
-from ctypes import cdll, c_void_p
+from ctypes import cdll, c_void_p, c_char_p, cast, Structure
 from libterraform.common import WINDOWS
 
 __version__ = '0.4.0'
@@ -8,9 +8,25 @@ root = os.path.dirname(os.path.abspath(__file__))
 _lib_filename = 'libterraform.dll' if WINDOWS else 'libterraform.so'
 _lib_tf = cdll.LoadLibrary(os.path.join(root, _lib_filename))
 
-_free = _lib_tf.Free
+_free = _lib_tf.FreeString
 _free.argtypes = [c_void_p]
 
+
+class _Result(Structure):
+    """(result, diags, error) C strings returned by most exports."""
+    _fields_ = [("r0", c_void_p),
+                ("r1", c_void_p),
+                ("r2", c_void_p)]
+
+
+def _decode_result(ret: _Result) -> (bytes, bytes, bytes):
+    """Read and free the C strings of the given result."""
+    values = []
+    for ptr in (ret.r0, ret.r1, ret.r2):
+        values.append(cast(ptr, c_char_p).value)
+        _free(ptr)
+    return tuple(values)
+
 from .cli import TerraformCommand
 from .config import TerraformConfig
 
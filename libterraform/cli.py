@@ -1,15 +1,95 @@
+import json as _json
 import os
 from ctypes import *
 from threading import Thread
 from typing import List, Sequence, Union
 
-from libterraform import _lib_tf
+from libterraform import _lib_tf, _free, _Result, _decode_result
 from libterraform.common import json_loads, WINDOWS, CmdType
-from libterraform.exceptions import TerraformCommandError, TerraformFdReadError
+from libterraform.exceptions import LibTerraformError, TerraformCommandError, TerraformFdReadError
 
 _run_cli = _lib_tf.RunCli
 _run_cli.argtypes = [c_int64, POINTER(c_char_p), c_int64, c_int64]
 
+_run_cli_with_options = _lib_tf.RunCliWithOptions
+_run_cli_with_options.argtypes = [c_int64, POINTER(c_char_p), c_int64, c_int64, c_char_p]
+
+TIMEOUT_RETCODE = 124
+PANIC_RETCODE = 11
+
+_cancel_run = _lib_tf.CancelRun
+_cancel_run.argtypes = [c_char_p, c_int64]
+
+_get_version = _lib_tf.GetVersion
+_get_version.restype = c_void_p
+
+_list_commands = _lib_tf.ListCommands
+_list_commands.restype = c_void_p
+
+_show_plan_json = _lib_tf.ShowPlanJSON
+_show_plan_json.argtypes = [c_char_p, c_char_p, c_char_p]
+_show_plan_json.restype = _Result
+
+_render_plan_summary = _lib_tf.RenderPlanSummary
+_render_plan_summary.argtypes = [c_char_p, c_int]
+_render_plan_summary.restype = c_void_p
+
+_show_state_json = _lib_tf.ShowStateJSON
+_show_state_json.argtypes = [c_char_p, c_char_p, c_char_p]
+_show_state_json.restype = _Result
+
+_workspace_state_serial = _lib_tf.WorkspaceStateSerial
+_workspace_state_serial.argtypes = [c_char_p, c_char_p]
+_workspace_state_serial.restype = _Result
+
+_state_provider_versions = _lib_tf.StateProviderVersions
+_state_provider_versions.argtypes = [c_char_p, c_char_p]
+_state_provider_versions.restype = _Result
+
+_validate_config = _lib_tf.ValidateConfig
+_validate_config.argtypes = [c_char_p]
+_validate_config.restype = _Result
+
+_find_removable_providers = _lib_tf.FindRemovableProviders
+_find_removable_providers.argtypes = [c_char_p, c_char_p]
+_find_removable_providers.restype = _Result
+
+_apply_with_limit = _lib_tf.ApplyWithLimit
+_apply_with_limit.argtypes = [c_char_p, c_char_p, c_int64]
+_apply_with_limit.restype = _Result
+
+_plan_change_summaries = _lib_tf.PlanChangeSummaries
+_plan_change_summaries.argtypes = [c_char_p, c_char_p]
+_plan_change_summaries.restype = _Result
+
+_target_blast_radius = _lib_tf.TargetBlastRadius
+_target_blast_radius.argtypes = [c_char_p, c_char_p, c_char_p]
+_target_blast_radius.restype = _Result
+
+_plan_replacement_strategy = _lib_tf.PlanReplacementStrategy
+_plan_replacement_strategy.argtypes = [c_char_p, c_char_p]
+_plan_replacement_strategy.restype = _Result
+
+_plan_approval_required = _lib_tf.PlanApprovalRequired
+_plan_approval_required.argtypes = [c_char_p, c_char_p, c_char_p]
+_plan_approval_required.restype = _Result
+
+_verify_idempotent = _lib_tf.VerifyIdempotent
+_verify_idempotent.argtypes = [c_char_p, c_char_p, c_int64]
+_verify_idempotent.restype = _Result
+
+_provider_schema = _lib_tf.ProviderSchema
+_provider_schema.argtypes = [c_char_p, c_char_p, c_char_p, c_char_p]
+_provider_schema.restype = _Result
+
+_load_dependency_lock = _lib_tf.LoadDependencyLock
+_load_dependency_lock.argtypes = [c_char_p]
+_load_dependency_lock.restype = _Result
+
+_init_working_dir = _lib_tf.InitWorkingDir
+_init_working_dir.argtypes = [c_char_p, c_char_p]
+_init_working_dir.restype = _Result
+
 
 def flag(value):
     return ... if value else None
@@ -45,11 +125,23 @@ class TerraformCommand:
             options: dict = None,
             chdir=None,
             check: bool = False,
-            json=False
+            json=False,
+            timeout: float = None,
+            stdin: Union[str, bytes, int] = None,
+            cli_config_file: str = None,
+            log_file: Union[str, int] = None,
+            log_level: str = None,
+            run_id: str = None,
+            data_dir: str = None,
+            plugin_cache_dir: str = None,
+            disable_checkpoint: bool = False,
     ) -> (int, str, str):
         """
         Run command with args and return a tuple (retcode, stdout, stderr).
 
+        If Terraform crashes, the return code is PANIC_RETCODE (11) and stderr holds
+        the crash message with its stack trace, rather than the process exiting.
+
         The returned object will have attributes retcode, value, json.
 
         If check is True and the return code was non 0 or 2, it raises a
@@ -70,8 +162,36 @@ class TerraformCommand:
                 list value will be converted to multi pairs.
                     ex. {"var": ["Name1=xx", "Name2=xx"]} -> -var Name1=xx -var Name2=xx
         :param chdir: Switch to a different working directory before executing the given subcommand.
+            The working directory of the process is restored when the command finishes,
+            and commands with chdir wait for each other since it is global to the process.
         :param check: Whether to check return code.
         :param json: Whether to load stdout as json. Only partial commands support json param.
+        :param timeout: Seconds after which the command is shut down, in which case
+            the return code is TIMEOUT_RETCODE (124). Like a killed Terraform process,
+            the state may have been partially written.
+        :param stdin: Input of the command, such as "yes\n" to answer the approval
+            prompt of apply. Can be str, bytes or a readable file descriptor.
+            Without stdin, commands fail right away when they prompt for input.
+        :param cli_config_file: Path of the CLI config file (.terraformrc) to use in place
+            of the one given by the TF_CLI_CONFIG_FILE environment variable or the default
+            one, such as to use different credentials or provider_installation per call.
+        :param log_file: Path of a file to append the logs of the command to, or a writable
+            file descriptor to write them to, whatever TF_LOG is set to. Terraform logs
+            through a single logger per process, so the logs of commands running at the
+            same time in other threads are written to it too: the logs are only those of
+            this command if it doesn't overlap with another one.
+        :param log_level: Level of the logs written to log_file: TRACE (default), DEBUG,
+            INFO, WARN or ERROR.
+        :param run_id: ID of the command to cancel it with cancel_run() from another thread.
+            Must be unique among the running commands.
+        :param data_dir: Data directory of the command in place of the one given by the
+            TF_DATA_DIR environment variable, or .terraform by default, such as to init
+            the same configuration into separate directories concurrently.
+        :param plugin_cache_dir: Plugin cache directory of the command in place of the
+            one of the CLI config.
+            Relative data_dir and plugin_cache_dir are relative to chdir, if given.
+        :param disable_checkpoint: Whether to skip the checkpoint call to HashiCorp for
+            upgrade and security bulletin information, whatever CHECKPOINT_DISABLE is set to.
         :return: Command result tuple (retcode, stdout, stderr).
         """
         argv = []
@@ -121,11 +241,50 @@ class TerraformCommand:
         stderr_thread.daemon = True
         stderr_thread.start()
 
+        r_stdin_fd = None
+        if isinstance(stdin, int):
+            # The fd is closed when the command finishes, so pass a duplicate.
+            r_stdin_fd = os.dup(stdin)
+        elif stdin is not None:
+            r_stdin_fd, w_stdin_fd = os.pipe()
+            stdin_thread = Thread(target=cls._fdwrite, args=(w_stdin_fd, stdin))
+            stdin_thread.daemon = True
+            stdin_thread.start()
+        # The log fd is closed when the command finishes too.
+        log_fd = os.dup(log_file) if isinstance(log_file, int) else None
+
         if WINDOWS:
             import msvcrt
-            w_stdout_handle = msvcrt.get_osfhandle(w_stdout_fd)
-            w_stderr_handle = msvcrt.get_osfhandle(w_stderr_fd)
-            retcode = _run_cli(argc, c_argv, w_stdout_handle, w_stderr_handle)
+            w_stdout_fd = msvcrt.get_osfhandle(w_stdout_fd)
+            w_stderr_fd = msvcrt.get_osfhandle(w_stderr_fd)
+            if r_stdin_fd is not None:
+                r_stdin_fd = msvcrt.get_osfhandle(r_stdin_fd)
+            if log_fd is not None:
+                log_fd = msvcrt.get_osfhandle(log_fd)
+        run_options = {}
+        if timeout is not None:
+            run_options['timeout_ms'] = int(timeout * 1000)
+        if r_stdin_fd is not None:
+            run_options['stdin_fd'] = r_stdin_fd
+        if cli_config_file:
+            run_options['cli_config_file'] = cli_config_file
+        if log_fd is not None:
+            run_options['log_fd'] = log_fd
+        elif log_file is not None:
+            run_options['log_path'] = log_file
+        if log_level:
+            run_options['log_level'] = log_level
+        if run_id:
+            run_options['run_id'] = run_id
+        if data_dir:
+            run_options['data_dir'] = data_dir
+        if plugin_cache_dir:
+            run_options['plugin_cache_dir'] = plugin_cache_dir
+        if disable_checkpoint:
+            run_options['disable_checkpoint'] = True
+        if run_options:
+            retcode = _run_cli_with_options(argc, c_argv, w_stdout_fd, w_stderr_fd,
+                                            _json.dumps(run_options).encode('utf-8'))
         else:
             retcode = _run_cli(argc, c_argv, w_stdout_fd, w_stderr_fd)
 
@@ -142,12 +301,39 @@ class TerraformCommand:
             raise TerraformCommandError(retcode, argv, stdout, stderr)
         return retcode, stdout, stderr
 
+    @staticmethod
+    def cancel_run(run_id: str, grace_period: float = 10) -> bool:
+        """
+        Cancel the running command started by run() with the given run_id.
+
+        The command is shut down gracefully first, like on Ctrl-C. If it is still
+        running after grace_period seconds, it is shut down forcibly like on a second
+        Ctrl-C, and its provider processes are killed. This returns right away,
+        without waiting for the command to finish.
+
+        :param run_id: ID given to run().
+        :param grace_period: Seconds to wait for a graceful shutdown.
+        :return: Whether the command was running.
+        """
+        return bool(_cancel_run(run_id.encode('utf-8'), int(grace_period * 1000)))
+
     @staticmethod
     def _fdread(std_fd, std_buffer):
         with os.fdopen(std_fd, encoding='utf-8') as std_f:
             std = std_f.read()
             std_buffer.append(std)
 
+    @staticmethod
+    def _fdwrite(std_fd, data):
+        if isinstance(data, str):
+            data = data.encode('utf-8')
+        try:
+            with os.fdopen(std_fd, 'wb') as std_f:
+                std_f.write(data)
+        except BrokenPipeError:
+            # The command finished without reading all of the input.
+            pass
+
     def version(self, check: bool = False, json: bool = True, **options) -> CommandResult:
         """Refer to https://www.terraform.io/docs/commands/version
 
@@ -163,6 +349,33 @@ class TerraformCommand:
         value = json_loads(stdout) if json else stdout
         return CommandResult(retcode, value, stderr, json)
 
+    @staticmethod
+    def get_version() -> dict:
+        """
+        Return the version details of the embedded Terraform, which are
+        version, prerelease, platform and go_version.
+
+        Unlike self.version(), this neither runs a command nor loads the CLI config.
+        """
+        ret = _get_version()
+        r_version = cast(ret, c_char_p).value
+        _free(ret)
+        return json_loads(r_version)
+
+    @staticmethod
+    def list_commands() -> List[dict]:
+        """
+        Return the commands of the embedded Terraform sorted by name, including
+        subcommands such as "state list", as dicts with name, primary and hidden.
+
+        Primary commands are listed first in the help output, while hidden ones,
+        such as the legacy "env" and its subcommands, are left out of it.
+        """
+        ret = _list_commands()
+        r_commands = cast(ret, c_char_p).value
+        _free(ret)
+        return json_loads(r_commands)
+
     def init(
             self,
             check: bool = False,
@@ -305,6 +518,25 @@ class TerraformCommand:
         value = json_loads(stdout) if json else stdout
         return CommandResult(retcode, value, stderr, json=json)
 
+    def validate_config(self) -> (dict, list):
+        """
+        Validate the configuration of self.cwd like self.validate(), directly rather
+        than by running the command, which is much faster when validating many
+        modules.
+
+        As for self.validate(), the modules and providers must be installed by
+        self.init(). Providers which are not installed are reported with a
+        "Provider not installed" diagnostic.
+
+        :return: (result, diags), result is a dict with Valid, ErrorCount and
+            WarningCount, or None if the validation could not run.
+        """
+        ret = _validate_config((self.cwd or '').encode('utf-8'))
+        r_result, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result), json_loads(r_diags)
+
     def plan(
             self,
             check: bool = False,
@@ -435,6 +667,120 @@ class TerraformCommand:
         value = json_loads(stdout) if json else stdout
         return CommandResult(retcode, value, stderr, json=json)
 
+    def show_plan_json(self, path: str, data_dir: str = None) -> (dict, list):
+        """
+        Read the given saved plan file and return it in the same JSON
+        representation as `terraform show -json`, without running the show command.
+
+        The plan is read in the context of the working directory it was created
+        in, which is self.cwd (or the current directory).
+
+        :param path: Path of the plan file, such as the out option of self.plan().
+            Same as self.show(), a relative path is relative to self.cwd.
+        :param data_dir: Data directory of the working directory. Defaults to .terraform.
+        :return: (plan, diags), plan is None if it could not be read due to diags.
+        """
+        if self.cwd:
+            path = os.path.join(self.cwd, path)
+        ret = _show_plan_json(path.encode('utf-8'),
+                              (self.cwd or '').encode('utf-8'),
+                              (data_dir or '').encode('utf-8'))
+        r_plan, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_plan), json_loads(r_diags)
+
+    @staticmethod
+    def render_plan_summary(plan: Union[dict, str], color: bool = False) -> str:
+        """
+        Render the human-readable summary of a plan the way the plan command does,
+        such as "Plan: 3 to add, 1 to change, 0 to destroy.", followed by the changes
+        to the outputs, if any, without planning again.
+
+        :param plan: Plan in the JSON representation of `terraform show -json`, such as
+            returned by self.show_plan_json(), as a dict or JSON text.
+        :param color: Whether to colorize the summary.
+        :return: The summary text, or an empty string if plan is invalid or has actions
+            this version of Terraform doesn't know.
+        """
+        if isinstance(plan, dict):
+            plan = _json.dumps(plan)
+        ret = _render_plan_summary(plan.encode('utf-8'), int(color))
+        r_summary = cast(ret, c_char_p).value
+        _free(ret)
+        return r_summary.decode('utf-8')
+
+    def show_state_json(self, data_dir: str = None, workspace: str = None) -> (dict, list):
+        """
+        Read the latest state of self.cwd (or the current directory) and return it in
+        the same JSON representation as `terraform show -json`, without running the
+        show command.
+
+        :param data_dir: Data directory of the working directory. Defaults to .terraform.
+        :param workspace: Workspace to read the state of. Defaults to the selected
+            workspace, which the TF_WORKSPACE environment variable can override.
+        :return: (state, diags), state is the representation of an empty state if there
+            is no state yet, or None if it could not be read due to diags.
+        """
+        ret = _show_state_json((self.cwd or '').encode('utf-8'),
+                               (data_dir or '').encode('utf-8'),
+                               (workspace or '').encode('utf-8'))
+        r_state, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_state), json_loads(r_diags)
+
+    def workspace_state_serial(self, workspace: str = None) -> (int, list):
+        """
+        Read the serial of the state of self.cwd, which is incremented on each change
+        of the state, to cheaply detect changes.
+
+        :param workspace: Workspace to read the state of. Defaults to the selected workspace.
+        :return: (serial, diags), serial is None if there is no state.
+        """
+        ret = _workspace_state_serial((self.cwd or '').encode('utf-8'), (workspace or '').encode('utf-8'))
+        r_serial, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_serial), json_loads(r_diags)
+
+    def state_provider_versions(self, workspace: str = None) -> (dict, list):
+        """
+        List the providers of the resources recorded in the state of self.cwd, for
+        upgrade planning.
+
+        The state records which provider each resource belongs to but not its
+        version, so the version is the one selected in .terraform.lock.hcl.
+
+        :param workspace: Workspace to read the state of. Defaults to the selected workspace.
+        :return: (providers, diags), providers is a dict with Workspace, TerraformVersion
+            (the version of Terraform which wrote the state) and Providers, a list of dicts
+            with Provider, Version and Resources.
+        """
+        ret = _state_provider_versions((self.cwd or '').encode('utf-8'), (workspace or '').encode('utf-8'))
+        r_result, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result), json_loads(r_diags)
+
+    def find_removable_providers(self, workspace: str = None) -> (list, list):
+        """
+        Find the providers recorded in the state or in .terraform.lock.hcl of self.cwd
+        which its configuration no longer requires, such as after removing their last
+        resource, for cleanup.
+
+        :param workspace: Workspace to read the state of. Defaults to the selected workspace.
+        :return: (providers, diags), each provider is a dict with Provider, Version
+            (selected in .terraform.lock.hcl) and Resources, the resources still in the
+            state with the provider. Until these are destroyed by self.apply(), the
+            provider is still needed.
+        """
+        ret = _find_removable_providers((self.cwd or '').encode('utf-8'), (workspace or '').encode('utf-8'))
+        r_result, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result), json_loads(r_diags)
+
     def apply(
             self,
             plan: str = None,
@@ -514,6 +860,120 @@ class TerraformCommand:
         value = json_loads(stdout, split=True) if json else stdout
         return CommandResult(retcode, value, stderr, json=json)
 
+    def apply_with_limit(self, max_changes: int, vars: dict = None) -> dict:
+        """
+        Apply at most max_changes of the resource changes planned for self.cwd,
+        then stop, for canary-style rollouts.
+
+        If more changes are planned, the changes are targeted in address order. As
+        targeting a change also applies the pending changes of its dependencies,
+        a change is skipped if the targeted plan would then exceed max_changes.
+
+        :param max_changes: Maximum number of resource changes to apply.
+        :param vars: Set variables in the root module of the configuration.
+        :return: Dict with Applied and Remaining resource instance addresses. Remaining
+            are the changes planned after the apply.
+        """
+        vars_json = _json.dumps(vars) if vars else ''
+        ret = _apply_with_limit((self.cwd or '').encode('utf-8'), vars_json.encode('utf-8'), max_changes)
+        r_result, _, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result)
+
+    def plan_change_summaries(self, vars: dict = None) -> list:
+        """
+        Plan self.cwd and return a one-line human-readable summary of each resource
+        change, such as "Create aws_instance.web", for notifications.
+
+        :param vars: Set variables in the root module of the configuration.
+        :return: List of summaries sorted by resource address. The verb is one of
+            Create, Update, Destroy and Replace.
+        """
+        vars_json = _json.dumps(vars) if vars else ''
+        ret = _plan_change_summaries((self.cwd or '').encode('utf-8'), vars_json.encode('utf-8'))
+        r_result, _, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result)
+
+    def target_blast_radius(self, target: str, vars: dict = None) -> list:
+        """
+        Plan self.cwd and return the addresses of all resources affected by a change
+        of the target, which are the changes of the plan targeting it and the changes
+        of the resources depending on it, directly or not.
+
+        :param target: Resource or module address, as passed to -target.
+        :param vars: Set variables in the root module of the configuration.
+        :return: List of resource instance addresses, sorted.
+        """
+        vars_json = _json.dumps(vars) if vars else ''
+        ret = _target_blast_radius((self.cwd or '').encode('utf-8'), target.encode('utf-8'),
+                                   vars_json.encode('utf-8'))
+        r_result, _, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result)
+
+    def plan_replacement_strategy(self, vars: dict = None) -> list:
+        """
+        Plan self.cwd and return how each resource planned to be replaced will be
+        replaced, as decided by create_before_destroy.
+
+        :param vars: Set variables in the root module of the configuration.
+        :return: List of dicts with Address and Strategy, which is either
+            "create_before_destroy" or "destroy_before_create".
+        """
+        vars_json = _json.dumps(vars) if vars else ''
+        ret = _plan_replacement_strategy((self.cwd or '').encode('utf-8'), vars_json.encode('utf-8'))
+        r_result, _, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result)
+
+    def plan_approval_required(self, policy: dict, vars: dict = None) -> list:
+        """
+        Plan self.cwd and return the resource changes requiring manual approval under
+        the given policy.
+
+        :param policy: Dict with rules, each a dict with a name and any of:
+            actions: Actions of the changes to match, such as ["delete"], as in
+                JSON plans. Replacements are both delete and create.
+            addresses: Patterns of the resource addresses to match, such as
+                ["module.prod.*"]. Only * is special, matching any characters, so
+                instance keys are matched literally, as in ["aws_instance.web[0]"].
+            resource_types: Resource types to match, such as ["aws_db_instance"].
+            A change matches a rule if it matches all its given fields.
+        :param vars: Set variables in the root module of the configuration.
+        :return: List of dicts with Address, Actions and Rules, the names of the
+            rules the change matches, sorted by address.
+        """
+        vars_json = _json.dumps(vars) if vars else ''
+        ret = _plan_approval_required((self.cwd or '').encode('utf-8'), vars_json.encode('utf-8'),
+                                      _json.dumps(policy).encode('utf-8'))
+        r_result, _, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result)
+
+    def verify_idempotent(self, vars: dict = None, apply: bool = True) -> dict:
+        """
+        Verify that applying self.cwd is idempotent, that is, a plan right after
+        an apply has no changes.
+
+        :param vars: Set variables in the root module of the configuration.
+        :param apply: Whether to apply first. If False, self.cwd is assumed to be
+            applied already.
+        :return: Dict with Idempotent and Changes, the resource changes planned after
+            the apply as dicts with Address and Action, such as "Update".
+        """
+        vars_json = _json.dumps(vars) if vars else ''
+        ret = _verify_idempotent((self.cwd or '').encode('utf-8'), vars_json.encode('utf-8'), int(apply))
+        r_result, _, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result)
+
     def destroy(
             self,
             check: bool = False,
@@ -964,6 +1424,84 @@ class TerraformCommand:
         """
         return self.providers(subcmd='schema', check=check, no_color=no_color, json=True, **options)
 
+    def provider_schema(self, source: str, version: str = None, plugin_dir: str = None) -> (dict, list):
+        """
+        Load the schema of an already installed provider without running init.
+
+        The result has the same structure as self.providers_schema(). The provider
+        is looked up in plugin_dir, or else in the .terraform/providers directory
+        of self.cwd and then the plugin cache directory. It is never installed.
+
+        :param source: Provider source address, such as "hashicorp/time".
+        :param version: Provider version. Defaults to the latest installed version.
+        :param plugin_dir: Directory the provider package is installed in,
+            laid out like .terraform/providers or the plugin cache directory.
+        :return: (schema, diags), schema is None if the provider is not available locally.
+        """
+        ret = _provider_schema(source.encode('utf-8'),
+                               (version or '').encode('utf-8'),
+                               (self.cwd or '').encode('utf-8'),
+                               (plugin_dir or '').encode('utf-8'))
+        r_schema, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_schema), json_loads(r_diags)
+
+    def load_dependency_lock(self) -> (dict, list):
+        """
+        Load the provider versions and hashes self.cwd is pinned to in its
+        dependency lock file, .terraform.lock.hcl.
+
+        :return: (lock, diags), lock is a dict with Exists, False if there is no lock
+            file, and Providers, a list of dicts with Source, Version, Constraints
+            and Hashes.
+        """
+        ret = _load_dependency_lock((self.cwd or '').encode('utf-8'))
+        r_lock, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_lock), json_loads(r_diags)
+
+    def init_working_dir(
+            self,
+            upgrade: bool = False,
+            backend: bool = True,
+            get: bool = True,
+            plugin_dir: str = None,
+            plugin_cache_dir: str = None,
+            cli_config_file: str = None,
+    ) -> (dict, list):
+        """
+        Run init in self.cwd and return what it did, rather than its text output.
+
+        :param upgrade: Install the latest module and provider versions allowed within
+            configured constraints, like -upgrade.
+        :param backend: Whether to initialize the backend, like -backend.
+        :param get: Whether to download modules, like -get.
+        :param plugin_dir: Directory containing plugin binaries, like -plugin-dir, which
+            together with plugin_cache_dir or cli_config_file allows to init offline.
+        :param plugin_cache_dir: Plugin cache directory in place of the one of the CLI config.
+        :param cli_config_file: Path of the CLI config file to use, as for run().
+        :return: (result, diags), result is a dict with Backend, the type of the
+            initialized backend or '' if backend is False, BackendChanged, Providers,
+            a list of dicts with Source, Version and PreviousVersion, which is ''
+            for newly selected providers, and Upgraded, True if any provider version
+            changed. If init fails, its error output is reported in diags.
+        """
+        options = {
+            'upgrade': upgrade,
+            'backend': backend,
+            'get': get,
+            'plugin_dir': plugin_dir or '',
+            'plugin_cache_dir': plugin_cache_dir or '',
+            'cli_config_file': cli_config_file or '',
+        }
+        ret = _init_working_dir((self.cwd or '').encode('utf-8'), _json.dumps(options).encode('utf-8'))
+        r_result, r_diags, err = _decode_result(ret)
+        if err:
+            raise LibTerraformError(err.decode('utf-8'))
+        return json_loads(r_result), json_loads(r_diags)
+
     def refresh(
             self,
             check: bool = False,
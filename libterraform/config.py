@@ -1,7 +1,8 @@
 import json
 from ctypes import *
+from typing import List
 
-from libterraform import _lib_tf, _free
+from libterraform import _lib_tf, _free, _Result, _decode_result
 from libterraform.exceptions import LibTerraformError
 
 
@@ -11,22 +12,129 @@ class LoadConfigDirResult(Structure):
                 ("r2", c_void_p)]
 
 
-_load_config_dir = _lib_tf.ConfigLoadConfigDir
-_load_config_dir.argtypes = [c_char_p]
+_load_config_dir = _lib_tf.ConfigLoadConfigDirWithOptions
+_load_config_dir.argtypes = [c_char_p, c_int]
 _load_config_dir.restype = LoadConfigDirResult
 
+_load_module_tree = _lib_tf.ConfigLoadModuleTreeWithOptions
+_load_module_tree.argtypes = [c_char_p, c_int]
+_load_module_tree.restype = _Result
+
+_moved_statements = _lib_tf.MovedStatements
+_moved_statements.argtypes = [c_char_p]
+_moved_statements.restype = _Result
+
+_check_module_versions = _lib_tf.CheckModuleVersions
+_check_module_versions.argtypes = [c_char_p, c_char_p]
+_check_module_versions.restype = _Result
+
+_resources_in_order = _lib_tf.ResourcesInOrder
+_resources_in_order.argtypes = [c_char_p]
+_resources_in_order.restype = _Result
+
+_check_provider_sources = _lib_tf.CheckProviderSources
+_check_provider_sources.argtypes = [c_char_p]
+_check_provider_sources.restype = _Result
+
+_secret_references = _lib_tf.SecretReferences
+_secret_references.argtypes = [c_char_p]
+_secret_references.restype = _Result
+
+_attack_surface_summary = _lib_tf.AttackSurfaceSummary
+_attack_surface_summary.argtypes = [c_char_p]
+_attack_surface_summary.restype = _Result
+
+_complexity_score = _lib_tf.ComplexityScore
+_complexity_score.argtypes = [c_char_p]
+_complexity_score.restype = _Result
+
+_find_deprecated_attributes = _lib_tf.FindDeprecatedAttributes
+_find_deprecated_attributes.argtypes = [c_char_p, c_char_p]
+_find_deprecated_attributes.restype = _Result
+
+_evaluate_locals = _lib_tf.EvaluateLocals
+_evaluate_locals.argtypes = [c_char_p, c_char_p]
+_evaluate_locals.restype = _Result
+
+_eval_expression = _lib_tf.EvalExpression
+_eval_expression.argtypes = [c_char_p, c_char_p]
+_eval_expression.restype = _Result
+
+_dynamic_resources = _lib_tf.DynamicResources
+_dynamic_resources.argtypes = [c_char_p]
+_dynamic_resources.restype = _Result
+
+_console_eval_batch = _lib_tf.ConsoleEvalBatch
+_console_eval_batch.argtypes = [c_char_p, c_char_p, c_char_p]
+_console_eval_batch.restype = _Result
+
+_check_output_references = _lib_tf.CheckOutputReferences
+_check_output_references.argtypes = [c_char_p]
+_check_output_references.restype = _Result
+
+_total_instance_count = _lib_tf.TotalInstanceCount
+_total_instance_count.argtypes = [c_char_p, c_char_p]
+_total_instance_count.restype = _Result
+
+_fmt_patch = _lib_tf.FmtPatch
+_fmt_patch.argtypes = [c_char_p]
+_fmt_patch.restype = _Result
+
+_fmt_module = _lib_tf.FmtModule
+_fmt_module.argtypes = [c_char_p]
+_fmt_module.restype = _Result
+
+_used_provider_functions = _lib_tf.UsedProviderFunctions
+_used_provider_functions.argtypes = [c_char_p]
+_used_provider_functions.restype = _Result
+
+_variables_by_requirement = _lib_tf.VariablesByRequirement
+_variables_by_requirement.argtypes = [c_char_p]
+_variables_by_requirement.restype = _Result
+
+_find_unlocked_providers = _lib_tf.FindUnlockedProviders
+_find_unlocked_providers.argtypes = [c_char_p]
+_find_unlocked_providers.restype = _Result
+
+_check_remote_state_references = _lib_tf.CheckRemoteStateReferences
+_check_remote_state_references.argtypes = [c_char_p]
+_check_remote_state_references.restype = _Result
+
+_provider_inheritance = _lib_tf.ProviderInheritance
+_provider_inheritance.argtypes = [c_char_p]
+_provider_inheritance.restype = _Result
+
+
+def _loads_result(ret: _Result) -> (object, list):
+    r_value, r_diags, err = _decode_result(ret)
+    if err:
+        raise LibTerraformError(err.decode('utf-8'))
+    return json.loads(r_value), json.loads(r_diags)
+
 
 class TerraformConfig:
     @staticmethod
-    def load_config_dir(path: str) -> (dict, dict):
+    def load_config_dir(path: str, dedupe: bool = False) -> (dict, dict):
         """
         load_config_dir reads the .tf and .tf.json files in the given directory
         as config files and then combines these files into a single Module.
 
         .tf files are parsed using the HCL native syntax while .tf.json files are
         parsed using the HCL JSON syntax.
+
+        ModuleSources holds the source of each module call parsed by Terraform, keyed
+        by name, as a dict with Raw, Type, Address and Version. Type is local, registry,
+        the installation method of a remote package, such as git, hg, http, s3 or gcs,
+        or invalid, in which case the source is also reported in diags.
+
+        Import and Checks hold the top-level import and check blocks of the .tf and
+        .tf.json files, which the embedded Terraform reports as unsupported in diags,
+        and are left out if there are none.
+
+        If dedupe is True, diagnostics with the same severity, summary and subject
+        range as an earlier one are removed.
         """
-        ret = _load_config_dir(path.encode('utf-8'))
+        ret = _load_config_dir(path.encode('utf-8'), int(dedupe))
         r_mod = cast(ret.r0, c_char_p).value
         _free(ret.r0)
         r_diags = cast(ret.r1, c_char_p).value
@@ -44,3 +152,351 @@ class TerraformConfig:
         diags = json.loads(r_diags)
 
         return mod, diags
+
+    @staticmethod
+    def load_module_tree(path: str, dedupe: bool = False) -> (dict, list):
+        """
+        load_module_tree loads the module in the given directory like load_config_dir,
+        together with all of its child modules with local sources, recursively.
+
+        Each node of the returned tree has the Path and Source of the module, its
+        Module like the one returned by load_config_dir, and its Children keyed
+        by module call name. Modules that aren't local, such as registry or git
+        modules, are not fetched but reported with Unresolved set instead.
+
+        :param path: Directory of the root module.
+        :param dedupe: Whether to remove diagnostics with the same severity, summary
+            and range as an earlier one, such as those reported for each call of
+            the same module.
+        :return: (tree, diags), each diagnostic has the path of its module.
+        """
+        return _loads_result(_load_module_tree(path.encode('utf-8'), int(dedupe)))
+
+    @staticmethod
+    def moved_statements(path: str) -> (list, list):
+        """
+        moved_statements finds the moved blocks of the module in the given directory
+        and its local child modules and validates them like a plan does, but without
+        any state, to surface conflicting or cyclic moves before an apply.
+
+        Besides the problems Terraform reports, a destination which is moved again
+        by another moved block is reported as "Chained move statements".
+
+        :param path: Directory of the module.
+        :return: (statements, diags), each statement is a dict with Module (empty for
+            the root module), From, To, DeclRange and Problems, the summaries of the
+            diags of the statement.
+        """
+        return _loads_result(_moved_statements(path.encode('utf-8')))
+
+    @staticmethod
+    def check_module_versions(path: str, cli_config_file: str = None) -> (list, list):
+        """
+        check_module_versions queries the module registry for the versions of
+        the registry modules called by the module in the given directory, to tell
+        whether a version satisfying the version constraint of each call exists.
+
+        :param path: Directory of the module.
+        :param cli_config_file: Path of the CLI config file (.terraformrc) with the
+            credentials and host services of the registries. Defaults to the one
+            Terraform finds itself.
+        :return: (modules, diags), each module call is a dict with Name, Source,
+            Constraint, Satisfiable, Version (the latest satisfying version), Error
+            (why the registry could not be queried) and DeclRange.
+        """
+        return _loads_result(_check_module_versions(path.encode('utf-8'), (cli_config_file or '').encode('utf-8')))
+
+    @staticmethod
+    def resources_in_order(path: str) -> (list, list):
+        """
+        resources_in_order returns the managed and data resources of the module in
+        the given directory in declaration order, by file name and then position
+        within the file.
+
+        :param path: Directory of the module.
+        :return: (resources, diags), each resource is a dict like the values of
+            ResourceDetails of load_config_dir.
+        """
+        return _loads_result(_resources_in_order(path.encode('utf-8')))
+
+    @staticmethod
+    def check_provider_sources(path: str) -> (list, list):
+        """
+        check_provider_sources finds the providers of the module in the given
+        directory whose source address is not fully-qualified, such as "aws"
+        instead of "hashicorp/aws", uses the legacy "-" namespace, or is missing
+        because the provider is not declared in required_providers.
+
+        :param path: Directory of the module.
+        :return: (problems, diags), each problem is a dict with Name, Source,
+            Provider, Reason, Detail and DeclRange.
+        """
+        return _loads_result(_check_provider_sources(path.encode('utf-8')))
+
+    @staticmethod
+    def secret_references(path: str) -> (list, list):
+        """
+        secret_references finds the data sources of the module in the given directory
+        that read secrets from a secret manager, such as vault_generic_secret or
+        aws_secretsmanager_secret_version.
+
+        :param path: Directory of the module.
+        :return: (secrets, diags), each secret is a dict with Address, Type, DeclRange
+            and Keys, the arguments identifying the secret with their static value
+            or else their expression source.
+        """
+        return _loads_result(_secret_references(path.encode('utf-8')))
+
+    @staticmethod
+    def attack_surface_summary(path: str) -> (list, list):
+        """
+        attack_surface_summary finds the resources of the module in the given
+        directory which may be publicly exposed, such as security groups open to
+        0.0.0.0/0 or public buckets, using heuristics on the static values of
+        their arguments.
+
+        :param path: Directory of the module.
+        :return: (resources, diags), each resource is a dict with Address, Type,
+            DeclRange and Reasons, why it is considered exposed.
+        """
+        return _loads_result(_attack_surface_summary(path.encode('utf-8')))
+
+    @staticmethod
+    def complexity_score(path: str) -> (dict, list):
+        """
+        complexity_score computes a heuristic complexity score of the module in the
+        given directory, for tracking tech debt across modules.
+
+        :param path: Directory of the module.
+        :return: (complexity, diags), complexity is a dict with the counts of
+            Resources, Modules, Conditionals, DynamicBlocks and Interpolations, and
+            Score, their weighted sum.
+        """
+        return _loads_result(_complexity_score(path.encode('utf-8')))
+
+    @staticmethod
+    def find_deprecated_attributes(path: str, schemas: dict = None) -> (list, list):
+        """
+        find_deprecated_attributes finds the resources of the module in the given
+        directory which set attributes or blocks marked deprecated by the schemas
+        of their providers.
+
+        :param path: Directory of the module.
+        :param schemas: Provider schemas in the format of `terraform providers schema -json`.
+            Defaults to the schemas of the providers installed for the directory by
+            `terraform init` or found in the plugin cache directory.
+        :return: (resources, diags), each resource is a dict with Address, Provider,
+            DeclRange and Attributes, the paths of the deprecated attributes set,
+            such as "root_block_device.iops".
+        """
+        schemas_json = json.dumps(schemas) if schemas else ''
+        return _loads_result(_find_deprecated_attributes(path.encode('utf-8'), schemas_json.encode('utf-8')))
+
+    @staticmethod
+    def evaluate_locals(path: str, vars: dict = None) -> (dict, list):
+        """
+        evaluate_locals evaluates the local values of the module in the given
+        directory with the given variables (or their defaults), including locals
+        that refer to other locals.
+
+        Locals depending on values only known during a plan, such as resource
+        attributes, are unknown.
+
+        :param path: Directory of the module.
+        :param vars: Values of the input variables.
+        :return: (locals, diags), locals is a dict of each local name to a dict with
+            Value and Known. Value is None if the local is unknown.
+        """
+        vars_json = json.dumps(vars) if vars else ''
+        return _loads_result(_evaluate_locals(path.encode('utf-8'), vars_json.encode('utf-8')))
+
+    @staticmethod
+    def eval_expression(expr: str, vars: dict = None) -> (dict, list):
+        """
+        eval_expression evaluates an expression, such as '"${var.env}-bucket"',
+        with the given variables and the standard Terraform functions, outside
+        of a plan.
+
+        References to anything other than the given variables, such as resource
+        attributes, are reported in diags.
+
+        :param expr: Expression in the Terraform language.
+        :param vars: Values of the variables, referenced as var.<name>.
+        :return: (value, diags), value is a dict with Value and Known, or None if
+            the expression could not be evaluated due to diags.
+        """
+        vars_json = json.dumps(vars) if vars else ''
+        return _loads_result(_eval_expression(expr.encode('utf-8'), vars_json.encode('utf-8')))
+
+    @staticmethod
+    def console_eval_batch(path: str, exprs: List[str], vars: dict = None) -> (list, list):
+        """
+        console_eval_batch evaluates several expressions, like `terraform console`
+        does, sharing the context of the module in the given directory: its
+        variables with the given values (or their defaults), its locals and the
+        standard Terraform functions.
+
+        Resource attributes and other values only known during a plan can't be
+        evaluated and are reported in the diagnostics of their expression.
+
+        :param path: Directory of the module, or None for a context with only the
+            given variables.
+        :param exprs: Expressions in the Terraform language.
+        :param vars: Values of the input variables.
+        :return: (results, diags), results has a dict for each expression with
+            Expression, Value (a dict with Value and Known, or None if it could not
+            be evaluated) and Diagnostics.
+        """
+        vars_json = json.dumps(vars) if vars else ''
+        ret = _console_eval_batch((path or '').encode('utf-8'), json.dumps(exprs).encode('utf-8'),
+                                  vars_json.encode('utf-8'))
+        return _loads_result(ret)
+
+    @staticmethod
+    def dynamic_resources(path: str) -> (list, list):
+        """
+        dynamic_resources finds the resources of the module in the given directory
+        whose number of instances is set by count or for_each.
+
+        :param path: Directory of the module.
+        :return: (resources, diags), each resource is a dict with Address, DeclRange,
+            MetaArgument ("count" or "for_each") and Expression, the source of the
+            meta-argument.
+        """
+        return _loads_result(_dynamic_resources(path.encode('utf-8')))
+
+    @staticmethod
+    def check_output_references(path: str) -> (list, list):
+        """
+        check_output_references finds the references of the outputs of the module in
+        the given directory to resources, variables, locals and modules which are not
+        declared in the module, such as a resource removed by a refactor.
+
+        :param path: Directory of the module.
+        :return: (references, diags), each reference is a dict with Output, Reference,
+            Summary, such as "Reference to undeclared resource", and DeclRange. Each one
+            is also reported as an error in diags.
+        """
+        return _loads_result(_check_output_references(path.encode('utf-8')))
+
+    @staticmethod
+    def total_instance_count(path: str, vars: dict = None) -> (dict, list):
+        """
+        total_instance_count counts the resource instances the module in the given
+        directory declares after count and for_each expansion, evaluated with the
+        given variables (or their defaults).
+
+        Resources whose count or for_each depends on values only known during
+        a plan, such as resource attributes, are listed in Unknown instead.
+
+        :param path: Directory of the module.
+        :param vars: Values of the input variables.
+        :return: (count, diags), count is a dict with Count and Unknown.
+        """
+        vars_json = json.dumps(vars) if vars else ''
+        return _loads_result(_total_instance_count(path.encode('utf-8'), vars_json.encode('utf-8')))
+
+    @staticmethod
+    def fmt_patch(path: str) -> (str, list):
+        """
+        fmt_patch formats the configuration and variables files in the given
+        directory and its subdirectories like `terraform fmt -recursive`, without
+        writing them, and returns all the changes as a single unified patch.
+
+        Hidden directories, such as .terraform, are skipped.
+
+        :param path: Directory of the files.
+        :return: (patch, diags), patch can be applied with `git apply` in the
+            directory and is empty if all files are formatted. Files which could
+            not be formatted are reported in diags and left out of the patch.
+        """
+        return _loads_result(_fmt_patch(path.encode('utf-8')))
+
+    @staticmethod
+    def fmt_module(files: dict) -> (dict, list):
+        """
+        fmt_module formats the given configuration and variables files in memory like
+        `terraform fmt`, without reading or writing any file.
+
+        :param files: Content of each file keyed by file name.
+        :return: (files, diags), files holds the formatted content keyed by the same
+            names. Files which could not be formatted are reported in diags and left out.
+        """
+        files_json = json.dumps(files)
+        return _loads_result(_fmt_module(files_json.encode('utf-8')))
+
+    @staticmethod
+    def used_provider_functions(path: str) -> (list, list):
+        """
+        used_provider_functions finds the calls of provider-defined functions, such as
+        provider::aws::arn_parse(...), in the .tf files of the module in the given
+        directory, for compatibility checks against Terraform v1.8 and later.
+
+        The files are scanned rather than parsed, so the calls are found even though
+        the embedded Terraform may not support them.
+
+        :param path: Directory of the module.
+        :return: (calls, diags), each call is a dict with Name, Provider, Function
+            and DeclRange.
+        """
+        return _loads_result(_used_provider_functions(path.encode('utf-8')))
+
+    @staticmethod
+    def variables_by_requirement(path: str) -> (dict, list):
+        """
+        variables_by_requirement groups the input variables of the module in the given
+        directory by whether a value must be given for them, such as to generate forms.
+
+        :param path: Directory of the module.
+        :return: (groups, diags), groups is a dict with the sorted names of the Required
+            variables, which have no default, and of the Optional ones.
+        """
+        return _loads_result(_variables_by_requirement(path.encode('utf-8')))
+
+    @staticmethod
+    def find_unlocked_providers(path: str) -> (list, list):
+        """
+        find_unlocked_providers finds the providers used by the resources of the module
+        in the given directory which are not selected in its .terraform.lock.hcl, such
+        as a provider missing from required_providers when the lock file was created.
+
+        :param path: Directory of the module.
+        :return: (providers, diags), each provider is a dict with Provider and the
+            Resources using it. Without a lock file, all providers are returned.
+        """
+        return _loads_result(_find_unlocked_providers(path.encode('utf-8')))
+
+    @staticmethod
+    def check_remote_state_references(path: str) -> (list, list):
+        """
+        check_remote_state_references reads the states of the terraform_remote_state data
+        sources of the module in the given directory and checks that the outputs the
+        module refers to, such as data.terraform_remote_state.network.outputs.vpc_id,
+        are provided by the states or the defaults of the data sources.
+
+        Relative paths in the config of the data sources are relative to the given
+        directory, as for a working directory.
+
+        :param path: Directory of the module.
+        :return: (references, diags), each reference is a dict with DataSource, Output,
+            Exists and DeclRange. Missing outputs are also reported as errors in diags,
+            while data sources whose state could not be read are reported as warnings
+            and their references left out.
+        """
+        return _loads_result(_check_remote_state_references(path.encode('utf-8')))
+
+    @staticmethod
+    def provider_inheritance(path: str) -> (list, list):
+        """
+        provider_inheritance returns which provider configuration of its parent each
+        local child module of the module in the given directory uses, whether passed in
+        the providers argument of the module call, such as providers = { aws = aws.west },
+        or inherited implicitly without that argument.
+
+        :param path: Directory of the module.
+        :return: (providers, diags), each provider is a dict with Module, the path of the
+            child module, InChild and InParent, the configuration as named in the child
+            and in the parent, Implicit and Resolved, the absolute address of the
+            configuration finally used, which is '' if the parent provides none.
+        """
+        return _loads_result(_provider_inheritance(path.encode('utf-8')))
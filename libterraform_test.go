@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLibterraformRecoverRunPanic(t *testing.T) {
+	var stderr bytes.Buffer
+	run := func() (exitCode int) {
+		defer recoverRunPanic(&stderr, &exitCode)
+		panic("boom")
+	}
+
+	if exitCode := run(); exitCode != panicExitCode {
+		t.Fatalf("wrong exit code %d; want %d", exitCode, panicExitCode)
+	}
+	got := stderr.String()
+	if !strings.HasPrefix(got, panicOutput+"boom\n\n") {
+		t.Fatalf("wrong stderr:\n%s", got)
+	}
+	if !strings.Contains(got, "TestLibterraformRecoverRunPanic") {
+		t.Fatalf("stack trace missing from stderr:\n%s", got)
+	}
+}
+
+func TestLibterraformRecoverRunPanicWithoutPanic(t *testing.T) {
+	var stderr bytes.Buffer
+	run := func() (exitCode int) {
+		defer recoverRunPanic(&stderr, &exitCode)
+		return 2
+	}
+
+	if exitCode := run(); exitCode != 2 {
+		t.Fatalf("wrong exit code %d; want 2", exitCode)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("unexpected stderr:\n%s", stderr.String())
+	}
+}
+
+func TestLibterraformAddressMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		addr    string
+		want    bool
+	}{
+		{"aws_instance.web[0]", "aws_instance.web[0]", true},
+		{"aws_instance.web[0]", "aws_instance.web0", false},
+		{"aws_instance.web[*]", "aws_instance.web[1]", true},
+		{"aws_instance.web[*]", "aws_instance.web", false},
+		{`module.x["a"].*`, `module.x["a"].aws_instance.web[0]`, true},
+		{`module.x["a"].*`, `module.x["b"].aws_instance.web`, false},
+		{"module.*.aws_instance.*", "module.prod.aws_instance.web", true},
+		{"*.web", "aws_instance.web", true},
+		{"*.web", "aws_instance.web2", false},
+		{"*", "aws_instance.web", true},
+	}
+	for _, test := range tests {
+		if got := addressMatch(test.pattern, test.addr); got != test.want {
+			t.Errorf("addressMatch(%q, %q) = %t; want %t", test.pattern, test.addr, got, test.want)
+		}
+	}
+}
+
+func TestLibterraformDiffLines(t *testing.T) {
+	a := []string{"a\n", "b\n", "c\n", "d\n"}
+	b := []string{"a\n", "x\n", "c\n", "d\n", "e\n"}
+	want := []diffLine{{' ', "a\n"}, {'-', "b\n"}, {'+', "x\n"}, {' ', "c\n"}, {' ', "d\n"}, {'+', "e\n"}}
+	if got := diffLines(a, b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong diff\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestLibterraformDiffLinesAllChanged(t *testing.T) {
+	// Every line changes, which is the worst case of the edit distance.
+	const n = 5000
+	a := make([]string, n)
+	b := make([]string, n)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d\n", i)
+		b[i] = fmt.Sprintf("b%d\n", i)
+	}
+
+	lines := diffLines(a, b)
+	if len(lines) != 2*n {
+		t.Fatalf("wrong number of lines %d; want %d", len(lines), 2*n)
+	}
+	for i, line := range lines {
+		want := byte('-')
+		if i >= n {
+			want = '+'
+		}
+		if line.kind != want {
+			t.Fatalf("wrong kind %q of line %d; want %q", line.kind, i, want)
+		}
+	}
+}
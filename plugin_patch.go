@@ -2,18 +2,131 @@ package plugin
 
 import (
 	"sync"
-	"sync/atomic"
 )
 
-func CleanupAndRemoveClients() {
-	// Set the killed to true so that we don't get unexpected panics
-	atomic.StoreUint32(&Killed, 1)
+// ClientScope tracks the managed clients created while it is open, such as
+// the provider plugins started by a run, so that they can be killed without
+// killing the ones of other scopes open at the same time.
+//
+// Clients don't record what created them, so a client is attributed to every
+// scope open when it was created: since the open scopes only change when one
+// is opened or closed, the clients seen for the first time then were created
+// by one of the scopes open until then. A client is killed when the last of
+// its scopes is closed.
+type ClientScope struct {
+	open bool
+}
 
-	// Kill all the managed clients in parallel and use a WaitGroup
-	// to wait for them all to finish up.
-	var wg sync.WaitGroup
+var (
+	// scopesLock guards the scopes, and is taken before managedClientsLock.
+	scopesLock sync.Mutex
+	openScopes = make(map[*ClientScope]struct{})
+	// clientScopes are the scopes each managed client seen so far may
+	// belong to, which are none for the clients created outside any scope.
+	clientScopes = make(map[*Client]map[*ClientScope]struct{})
+)
+
+// OpenClientScope opens a scope tracking the managed clients created until it
+// is closed.
+func OpenClientScope() *ClientScope {
+	s := &ClientScope{open: true}
+	scopesLock.Lock()
+	attributeClients()
+	openScopes[s] = struct{}{}
+	scopesLock.Unlock()
+	return s
+}
+
+// Close closes the scope and kills its clients, except the ones which may
+// also belong to other scopes still open, which are killed when the last of
+// those is closed.
+func (s *ClientScope) Close() {
+	scopesLock.Lock()
+	if !s.open {
+		scopesLock.Unlock()
+		return
+	}
+	attributeClients()
+	s.open = false
+	delete(openScopes, s)
+	var clients []*Client
+	for client, scopes := range clientScopes {
+		if _, ok := scopes[s]; !ok {
+			continue
+		}
+		delete(scopes, s)
+		if len(scopes) == 0 {
+			clients = append(clients, client)
+		}
+	}
+	removeClients(clients)
+	scopesLock.Unlock()
+
+	killClients(clients)
+}
+
+// Kill kills the clients of the scope right away, such as to stop a run which
+// is stuck, while the scope stays open. The clients which may also belong to
+// other open scopes are left running.
+func (s *ClientScope) Kill() {
+	scopesLock.Lock()
+	attributeClients()
+	var clients []*Client
+	for client, scopes := range clientScopes {
+		if _, ok := scopes[s]; ok && len(scopes) == 1 {
+			clients = append(clients, client)
+		}
+	}
+	removeClients(clients)
+	scopesLock.Unlock()
+
+	killClients(clients)
+}
+
+// attributeClients attributes the managed clients which are not yet to the
+// open scopes. scopesLock must be held.
+func attributeClients() {
 	managedClientsLock.Lock()
+	defer managedClientsLock.Unlock()
 	for _, client := range managedClients {
+		if _, ok := clientScopes[client]; ok {
+			continue
+		}
+		scopes := make(map[*ClientScope]struct{}, len(openScopes))
+		for s := range openScopes {
+			scopes[s] = struct{}{}
+		}
+		clientScopes[client] = scopes
+	}
+}
+
+// removeClients stops tracking the given clients, which are about to be
+// killed. scopesLock must be held.
+func removeClients(clients []*Client) {
+	if len(clients) == 0 {
+		return
+	}
+	removed := make(map[*Client]struct{}, len(clients))
+	for _, client := range clients {
+		removed[client] = struct{}{}
+		delete(clientScopes, client)
+	}
+	managedClientsLock.Lock()
+	kept := managedClients[:0]
+	for _, client := range managedClients {
+		if _, ok := removed[client]; !ok {
+			kept = append(kept, client)
+		}
+	}
+	managedClients = kept
+	managedClientsLock.Unlock()
+}
+
+// killClients kills the given clients in parallel and waits for them all to
+// finish up.
+func killClients(clients []*Client) {
+	var wg sync.WaitGroup
+	for _, client := range clients {
 		wg.Add(1)
 
 		go func(client *Client) {
@@ -21,8 +134,5 @@ func CleanupAndRemoveClients() {
 			wg.Done()
 		}(client)
 	}
-	managedClients = managedClients[:0]
-	managedClientsLock.Unlock()
-
 	wg.Wait()
 }
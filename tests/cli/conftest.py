@@ -1,4 +1,5 @@
 import os
+import shutil
 
 import pytest
 
@@ -15,3 +16,17 @@ def cli():
     if not os.path.exists(tf):
         cli.init()
     return cli
+
+
+@pytest.fixture
+def tmp_sleep_config(tmp_path):
+    # A private copy of the sleep configuration which has not been initialized.
+    cwd = str(tmp_path / 'sleep')
+    shutil.copytree(TF_SLEEP_DIR, cwd, ignore=shutil.ignore_patterns('.terraform*', '*.tfstate*', '*.tfplan'))
+    return cwd
+
+
+@pytest.fixture
+def tmp_sleep_dir(tmp_sleep_config):
+    TerraformCommand(tmp_sleep_config).init(check=True)
+    return tmp_sleep_config
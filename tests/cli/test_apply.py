@@ -1,6 +1,8 @@
 import os
+import shutil
 
 from libterraform import TerraformCommand
+from tests.consts import TF_CHAIN_DIR, TF_IDEMPOTENT_DIR, TF_STEPS_DIR
 
 
 class TestTerraformCommandApply:
@@ -19,3 +21,51 @@ class TestTerraformCommandApply:
         r = cli.apply(tfplan_path)
         assert r.retcode == 0, r.error
         assert isinstance(r.value, list)
+
+    def test_apply_with_limit(self, tmp_path):
+        cwd = str(tmp_path / 'steps')
+        shutil.copytree(TF_STEPS_DIR, cwd)
+        cli = TerraformCommand(cwd)
+        cli.init(check=True)
+
+        r = cli.apply_with_limit(1)
+        assert len(r['Applied']) == 1
+        assert len(r['Remaining']) == 2
+        assert not set(r['Applied']) & set(r['Remaining'])
+
+    def test_apply_with_limit_pending_dependency(self, tmp_path):
+        cwd = str(tmp_path / 'chain')
+        shutil.copytree(TF_CHAIN_DIR, cwd)
+        cli = TerraformCommand(cwd)
+        cli.init(check=True)
+
+        # Targeting time_static.app would also apply its dependency.
+        r = cli.apply_with_limit(1)
+        assert r == {'Applied': ['time_static.network'], 'Remaining': ['time_static.app']}
+        r = cli.apply_with_limit(1)
+        assert r == {'Applied': ['time_static.app'], 'Remaining': []}
+
+    def test_apply_with_stdin(self, tmp_sleep_dir):
+        cwd = tmp_sleep_dir
+        retcode, stdout, stderr = TerraformCommand.run('apply', options={'no_color': ...}, chdir=cwd, stdin='yes\n')
+        assert retcode == 0, stderr
+        assert 'Apply complete!' in stdout
+
+    def test_apply_without_stdin(self, tmp_sleep_dir):
+        cwd = tmp_sleep_dir
+        retcode, stdout, stderr = TerraformCommand.run('apply', options={'no_color': ...}, chdir=cwd, timeout=60)
+        assert retcode == 1
+        assert not os.path.exists(os.path.join(cwd, 'terraform.tfstate'))
+
+    def test_verify_idempotent(self, tmp_path):
+        cwd = str(tmp_path / 'idempotent')
+        shutil.copytree(TF_IDEMPOTENT_DIR, cwd)
+        cli = TerraformCommand(cwd)
+        cli.init(check=True)
+
+        r = cli.verify_idempotent()
+        assert r == {'Idempotent': True, 'Changes': []}
+
+        r = cli.verify_idempotent(vars={'drift': True})
+        assert r['Idempotent'] is False
+        assert r['Changes'] == [{'Address': 'time_static.drifting', 'Action': 'DeleteThenCreate'}]
@@ -0,0 +1,17 @@
+from libterraform import TerraformCommand
+
+
+class TestTerraformCommandListCommands:
+    def test_list_commands(self):
+        commands = TerraformCommand.list_commands()
+        names = [c['name'] for c in commands]
+        assert names == sorted(names)
+        for name in ('init', 'plan', 'apply', 'state list', 'state rm', 'workspace new', 'providers schema'):
+            assert name in names
+
+        by_name = {c['name']: c for c in commands}
+        assert [c['name'] for c in commands if c['primary']] == ['apply', 'destroy', 'init', 'plan', 'validate']
+        assert by_name['env']['hidden'] is True
+        assert by_name['env list']['hidden'] is True
+        assert by_name['workspace list']['hidden'] is False
+
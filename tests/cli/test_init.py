@@ -1,8 +1,121 @@
+import os
+import shutil
+import threading
+import time
+
 from libterraform import TerraformCommand
 from tests.consts import TF_SLEEP_DIR
 
 
+def _write_cli_config(path, mirror):
+    with open(path, 'w') as f:
+        f.write(f'''
+provider_installation {{
+  filesystem_mirror {{
+    path    = "{mirror.replace(os.sep, '/')}"
+    include = ["registry.terraform.io/*/*"]
+  }}
+  direct {{
+    exclude = ["registry.terraform.io/*/*"]
+  }}
+}}
+''')
+
+
 class TestTerraformCommandInit:
     def test_init(self):
         r = TerraformCommand(TF_SLEEP_DIR).init()
         assert r.retcode == 0, r.error
+
+    def test_init_with_cli_config_file(self, cli: TerraformCommand, tmp_sleep_config, tmp_path):
+        cwd = tmp_sleep_config
+        mirror = os.path.join(TF_SLEEP_DIR, '.terraform', 'providers')
+        config_file = str(tmp_path / 'mirror.tfrc')
+        _write_cli_config(config_file, mirror)
+        retcode, stdout, stderr = TerraformCommand.run(
+            'init', options={'input': False, 'no_color': ...}, chdir=cwd, cli_config_file=config_file,
+        )
+        assert retcode == 0, stderr
+
+        empty_mirror = str(tmp_path / 'empty')
+        os.mkdir(empty_mirror)
+        config_file = str(tmp_path / 'empty.tfrc')
+        _write_cli_config(config_file, empty_mirror)
+        shutil.rmtree(os.path.join(cwd, '.terraform'))
+        os.remove(os.path.join(cwd, '.terraform.lock.hcl'))
+        retcode, stdout, stderr = TerraformCommand.run(
+            'init', options={'input': False, 'no_color': ...}, chdir=cwd, cli_config_file=config_file,
+        )
+        assert retcode == 1
+        assert 'hashicorp/time' in stderr
+
+    def test_init_working_dir(self, cli: TerraformCommand, tmp_sleep_config, tmp_path):
+        cwd = tmp_sleep_config
+        mirror = os.path.join(TF_SLEEP_DIR, '.terraform', 'providers')
+        config_file = str(tmp_path / 'mirror.tfrc')
+        _write_cli_config(config_file, mirror)
+
+        result, diags = TerraformCommand(cwd).init_working_dir(cli_config_file=config_file)
+        assert diags == []
+        assert result['Backend'] == 'local'
+        assert result['BackendChanged'] is False
+        assert result['Upgraded'] is False
+        [provider] = result['Providers']
+        assert provider['Source'] == 'registry.terraform.io/hashicorp/time'
+        assert provider['Version']
+        assert provider['PreviousVersion'] == ''
+
+        result, diags = TerraformCommand(cwd).init_working_dir(backend=False, get=False, cli_config_file=config_file)
+        assert diags == []
+        assert result['Backend'] == ''
+        assert result['Providers'][0]['PreviousVersion'] == provider['Version']
+
+    def test_init_working_dir_failed(self, cli: TerraformCommand, tmp_sleep_config, tmp_path):
+        cwd = tmp_sleep_config
+        empty_mirror = str(tmp_path / 'empty')
+        os.mkdir(empty_mirror)
+        config_file = str(tmp_path / 'empty.tfrc')
+        _write_cli_config(config_file, empty_mirror)
+
+        result, diags = TerraformCommand(cwd).init_working_dir(cli_config_file=config_file)
+        assert result['Providers'] == []
+        assert diags[0]['summary'] == 'Failed to initialize'
+        assert 'hashicorp/time' in diags[0]['detail']
+
+    def test_init_with_data_dir(self, cli: TerraformCommand, tmp_sleep_config, tmp_path, monkeypatch):
+        cwd = tmp_sleep_config
+        mirror = os.path.join(TF_SLEEP_DIR, '.terraform', 'providers')
+        config_file = str(tmp_path / 'mirror.tfrc')
+        _write_cli_config(config_file, mirror)
+
+        # Runs which don't switch the working dir don't wait for each other.
+        monkeypatch.chdir(cwd)
+        data_dirs = [str(tmp_path / 'data1'), 'data2']
+        barrier = threading.Barrier(len(data_dirs))
+        results = {}
+        intervals = {}
+
+        def init(data_dir):
+            barrier.wait()
+            start = time.monotonic()
+            results[data_dir] = TerraformCommand.run(
+                'init', options={'input': False, 'no_color': ...},
+                cli_config_file=config_file, data_dir=data_dir,
+            )
+            intervals[data_dir] = (start, time.monotonic())
+
+        threads = [threading.Thread(target=init, args=(data_dir,)) for data_dir in data_dirs]
+        for thread in threads:
+            thread.start()
+        for thread in threads:
+            thread.join()
+
+        for data_dir in data_dirs:
+            retcode, stdout, stderr = results[data_dir]
+            assert retcode == 0, stderr
+            # A relative data dir is relative to the working dir.
+            providers = os.path.join(cwd, data_dir, 'providers', 'registry.terraform.io', 'hashicorp', 'time')
+            assert os.path.isdir(providers)
+        starts, ends = zip(*intervals.values())
+        assert max(starts) < min(ends)
+        assert not os.path.exists(os.path.join(cwd, '.terraform'))
@@ -1,4 +1,7 @@
+import shutil
+
 from libterraform import TerraformCommand
+from tests.consts import TF_BLAST_DIR, TF_REPLACE_DIR
 
 
 class TestTerraformCommandPlan:
@@ -11,3 +14,81 @@ class TestTerraformCommandPlan:
         r = cli.plan(vars={'time1': '1s', 'time2': '2s'})
         assert r.retcode == 0, r.error
         assert isinstance(r.value, list)
+
+    def test_plan_replacement_strategy(self, tmp_path):
+        cwd = str(tmp_path / 'replace')
+        shutil.copytree(TF_REPLACE_DIR, cwd)
+        cli = TerraformCommand(cwd)
+        cli.init(check=True)
+        cli.apply(check=True)
+
+        assert cli.plan_replacement_strategy() == []
+        strategies = cli.plan_replacement_strategy(vars={'key': 'b'})
+        assert strategies == [
+            {'Address': 'time_static.cbd', 'Strategy': 'create_before_destroy'},
+            {'Address': 'time_static.dbc', 'Strategy': 'destroy_before_create'},
+        ]
+
+    def test_plan_change_summaries(self, tmp_path):
+        main_tf = tmp_path / 'main.tf'
+        main_tf.write_text('resource "time_static" "old" {}\n')
+        cli = TerraformCommand(str(tmp_path))
+        cli.init(check=True)
+        cli.apply(check=True)
+
+        assert cli.plan_change_summaries() == []
+        main_tf.write_text('resource "time_static" "new" {}\n')
+        assert cli.plan_change_summaries() == [
+            'Create time_static.new',
+            'Destroy time_static.old',
+        ]
+
+    def test_target_blast_radius(self, tmp_path):
+        cwd = str(tmp_path / 'blast')
+        shutil.copytree(TF_BLAST_DIR, cwd)
+        cli = TerraformCommand(cwd)
+        cli.init(check=True)
+        cli.apply(check=True)
+
+        assert cli.target_blast_radius('time_static.base') == []
+        assert cli.target_blast_radius('time_static.base', vars={'key': 'b'}) == [
+            'time_static.base',
+            'time_static.dependent',
+            'time_static.indirect',
+        ]
+
+    def test_plan_approval_required(self, tmp_path):
+        main_tf = tmp_path / 'main.tf'
+        main_tf.write_text('resource "time_static" "kept" {}\n\nresource "time_static" "removed" {}\n')
+        cli = TerraformCommand(str(tmp_path))
+        cli.init(check=True)
+        cli.apply(check=True)
+
+        main_tf.write_text('resource "time_static" "kept" {}\n\nresource "time_static" "added" {}\n')
+        policy = {'rules': [{'name': 'deletes require approval', 'actions': ['delete']}]}
+        assert cli.plan_approval_required(policy) == [
+            {'Address': 'time_static.removed', 'Actions': ['delete'], 'Rules': ['deletes require approval']},
+        ]
+
+    def test_plan_approval_required_indexed_address(self, tmp_path):
+        (tmp_path / 'main.tf').write_text(
+            'resource "time_static" "web" {\n  count = 2\n}\n\n'
+            'resource "time_static" "web0" {}\n\n'
+            'resource "time_static" "tagged" {\n  for_each = toset(["a", "b"])\n}\n'
+        )
+        cli = TerraformCommand(str(tmp_path))
+        cli.init(check=True)
+
+        policy = {'rules': [
+            {'name': 'first web', 'addresses': ['time_static.web[0]']},
+            {'name': 'tagged a', 'addresses': ['time_static.tagged["a"]']},
+        ]}
+        assert cli.plan_approval_required(policy) == [
+            {'Address': 'time_static.tagged["a"]', 'Actions': ['create'], 'Rules': ['tagged a']},
+            {'Address': 'time_static.web[0]', 'Actions': ['create'], 'Rules': ['first web']},
+        ]
+
+        policy = {'rules': [{'name': 'webs', 'addresses': ['time_static.web[*]']}]}
+        assert [c['Address'] for c in cli.plan_approval_required(policy)] == [
+            'time_static.web[0]', 'time_static.web[1]',
+        ]
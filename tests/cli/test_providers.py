@@ -18,3 +18,32 @@ class TestTerraformCommandProviders:
         r = cli.providers_schema()
         assert r.retcode == 0, r.error
         assert isinstance(r.value, dict)
+
+    def test_provider_schema(self, cli: TerraformCommand):
+        schema, diags = cli.provider_schema('hashicorp/time')
+        assert schema is not None, diags
+        assert 'time_sleep' in schema['provider_schemas']['registry.terraform.io/hashicorp/time']['resource_schemas']
+
+    def test_provider_schema_plugin_dir(self, cli: TerraformCommand):
+        plugin_dir = os.path.join(cli.cwd, '.terraform', 'providers')
+        schema, diags = TerraformCommand().provider_schema('hashicorp/time', plugin_dir=plugin_dir)
+        assert 'registry.terraform.io/hashicorp/time' in schema['provider_schemas']
+
+    def test_provider_schema_not_installed(self, cli: TerraformCommand):
+        schema, diags = cli.provider_schema('hashicorp/not-installed')
+        assert schema is None
+        assert diags[0]['summary'] == 'Provider not installed'
+
+    def test_load_dependency_lock(self, cli: TerraformCommand):
+        lock, diags = cli.load_dependency_lock()
+        assert not diags
+        assert lock['Exists'] is True
+        provider = lock['Providers'][0]
+        assert provider['Source'] == 'registry.terraform.io/hashicorp/time'
+        assert provider['Version']
+        assert provider['Hashes']
+
+    def test_load_dependency_lock_not_exists(self, tmp_path):
+        lock, diags = TerraformCommand(str(tmp_path)).load_dependency_lock()
+        assert not diags
+        assert lock == {'Exists': False, 'Providers': []}
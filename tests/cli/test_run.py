@@ -1,7 +1,13 @@
+import os
+import threading
+import time
+
 import pytest
 
 from libterraform import TerraformCommand
+from libterraform.cli import TIMEOUT_RETCODE
 from libterraform.exceptions import TerraformCommandError
+from tests.consts import TF_SLEEP_DIR
 
 
 class TestTerraformCommandRun:
@@ -10,6 +16,17 @@ class TestTerraformCommandRun:
         assert retcode == 0
         assert 'Terraform' in stdout
 
+    def test_run_version_disable_checkpoint(self):
+        start = time.monotonic()
+        retcode, stdout, stderr = TerraformCommand.run('version', disable_checkpoint=True)
+        assert retcode == 0, stderr
+        assert 'Terraform' in stdout
+        assert time.monotonic() - start < 5
+
+        retcode, stdout, stderr = TerraformCommand.run('version', json=True, disable_checkpoint=True)
+        assert retcode == 0, stderr
+        assert '"terraform_outdated": false' in stdout
+
     def test_run_invalid(self):
         retcode, stdout, stderr = TerraformCommand.run('invalid')
         assert retcode == 1
@@ -17,3 +34,75 @@ class TestTerraformCommandRun:
 
         with pytest.raises(TerraformCommandError):
             TerraformCommand.run('invalid', check=True)
+
+    def test_run_chdir_restores_cwd(self, tmp_path):
+        cwd = os.getcwd()
+        retcode, stdout, stderr = TerraformCommand.run('validate', chdir=TF_SLEEP_DIR)
+        assert retcode == 0, stderr
+        assert os.getcwd() == cwd
+
+        retcode, stdout, stderr = TerraformCommand.run('invalid', chdir=str(tmp_path))
+        assert retcode == 1
+        assert os.getcwd() == cwd
+
+    def test_run_timeout(self, tmp_sleep_dir):
+        cwd = tmp_sleep_dir
+        start = time.time()
+        retcode, stdout, stderr = TerraformCommand.run(
+            'apply', options={'auto_approve': ..., 'input': False, 'var': ['time1=60s']},
+            chdir=cwd, timeout=1,
+        )
+        assert retcode == TIMEOUT_RETCODE
+        assert 'timeout' in stderr
+        assert time.time() - start < 60
+
+    def test_run_log_file(self, tmp_path):
+        log_path = str(tmp_path / 'version.log')
+        retcode, stdout, stderr = TerraformCommand.run('version', log_file=log_path)
+        assert retcode == 0
+        with open(log_path) as f:
+            log = f.read()
+        assert 'Terraform version' in log
+        assert "CLI args: []string{\"Terraform\", \"version\"}" in log
+
+        # The sink is removed when the run finishes.
+        TerraformCommand.run('validate', chdir=TF_SLEEP_DIR)
+        with open(log_path) as f:
+            assert f.read() == log
+
+    def test_run_log_level(self, tmp_path):
+        log_path = str(tmp_path / 'version.log')
+        TerraformCommand.run('version', log_file=log_path, log_level='INFO')
+        with open(log_path) as f:
+            log = f.read()
+        assert '[INFO]' in log
+        assert '[TRACE]' not in log
+
+        with open(log_path, 'w') as f:
+            TerraformCommand.run('version', log_file=f.fileno())
+        with open(log_path) as f:
+            assert '[TRACE]' in f.read()
+
+        retcode, stdout, stderr = TerraformCommand.run('version', log_level='LOUD', log_file=log_path)
+        assert retcode == 1
+        assert 'invalid log level' in stderr
+
+    def test_cancel_run(self, tmp_sleep_dir):
+        cwd = tmp_sleep_dir
+        assert TerraformCommand.cancel_run('not-running') is False
+
+        result = []
+        thread = threading.Thread(target=lambda: result.append(TerraformCommand.run(
+            'apply', options={'auto_approve': ..., 'input': False, 'var': ['time1=60s']},
+            chdir=cwd, run_id='sleep',
+        )))
+        start = time.time()
+        thread.start()
+        while not TerraformCommand.cancel_run('sleep', grace_period=1):
+            assert time.time() - start < 30
+            time.sleep(0.1)
+        thread.join()
+        assert time.time() - start < 60
+        retcode, stdout, stderr = result[0]
+        assert retcode != 0
+        assert TerraformCommand.cancel_run('sleep') is False
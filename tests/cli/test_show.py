@@ -1,4 +1,7 @@
+import pytest
+
 from libterraform import TerraformCommand
+from libterraform.exceptions import LibTerraformError
 
 
 class TestTerraformCommandShow:
@@ -14,3 +17,107 @@ class TestTerraformCommandShow:
         for key in ('format_version', 'terraform_version', 'variables', 'planned_values',
                     'resource_changes', 'configuration'):
             assert key in r.value
+
+    def test_show_plan_json(self, cli: TerraformCommand):
+        plan_path = 'sleep.tfplan'
+        cli.plan(out=plan_path)
+        plan, diags = cli.show_plan_json(plan_path)
+        assert not diags
+        for key in ('format_version', 'terraform_version', 'variables', 'planned_values',
+                    'resource_changes', 'configuration'):
+            assert key in plan
+
+    def test_show_plan_json_not_exists(self, cli: TerraformCommand):
+        with pytest.raises(LibTerraformError):
+            cli.show_plan_json('not-exists.tfplan')
+
+    def test_show_state_json(self, tmp_sleep_dir):
+        cli = TerraformCommand(tmp_sleep_dir)
+
+        state, diags = cli.show_state_json()
+        assert state == {'format_version': '1.0'}
+
+        cli.apply(check=True)
+        state, diags = cli.show_state_json()
+        assert not diags
+        resources = state['values']['root_module']['resources']
+        assert [r['address'] for r in resources] == ['time_sleep.wait1', 'time_sleep.wait2']
+        assert resources[0]['values']['create_duration'] == '1s'
+
+        cli.workspace_new('other', check=True)
+        state, diags = cli.show_state_json()
+        assert 'values' not in state
+        state, diags = cli.show_state_json(workspace='default')
+        assert len(state['values']['root_module']['resources']) == 2
+
+    def test_render_plan_summary(self):
+        plan = {
+            'resource_changes': [
+                {'address': 'time_sleep.a', 'mode': 'managed', 'change': {'actions': ['create']}},
+                {'address': 'time_sleep.b', 'mode': 'managed', 'change': {'actions': ['delete', 'create']}},
+                {'address': 'time_sleep.c', 'mode': 'managed', 'change': {'actions': ['update']}},
+                {'address': 'time_sleep.d', 'mode': 'managed', 'change': {'actions': ['no-op']}},
+                {'address': 'data.x.y', 'mode': 'data', 'change': {'actions': ['read']}},
+            ],
+            'output_changes': {
+                'id': {'actions': ['create'], 'before': None, 'after': 'abc'},
+                'same': {'actions': ['no-op'], 'before': 1, 'after': 1},
+            },
+        }
+        summary = TerraformCommand.render_plan_summary(plan)
+        assert summary == 'Plan: 2 to add, 1 to change, 1 to destroy.\n\nChanges to Outputs:\n  + id = "abc"\n'
+        colored = TerraformCommand.render_plan_summary(plan, color=True)
+        assert '\x1b[' in colored and 'Plan:' in colored
+
+    def test_render_plan_summary_no_changes(self):
+        summary = TerraformCommand.render_plan_summary({'resource_changes': [
+            {'address': 'time_sleep.a', 'mode': 'managed', 'change': {'actions': ['no-op']}},
+        ]})
+        assert summary.startswith('No changes. Your infrastructure matches the configuration.')
+
+    def test_render_plan_summary_only_outputs(self):
+        summary = TerraformCommand.render_plan_summary({'output_changes': {
+            'id': {'actions': ['update'], 'before': 'a', 'after': 'b'},
+            'next': {'actions': ['create'], 'before': None, 'after': None, 'after_unknown': True},
+            'removed': {'actions': ['delete'], 'before': 'x', 'after': None},
+            'secret': {
+                'actions': ['update'], 'before': 'a', 'after': 'b',
+                'before_sensitive': True, 'after_sensitive': True,
+            },
+        }})
+        assert summary.startswith(
+            'Changes to Outputs:\n'
+            '  ~ id      = "a" -> "b"\n'
+            '  + next    = (known after apply)\n'
+            '  - removed = "x" -> null\n'
+            '  ~ secret  = (sensitive value)\n'
+            '\nYou can apply this plan to save these new output values'
+        )
+
+    def test_render_plan_summary_matches_plan(self, tmp_sleep_dir):
+        options = {'input': False, 'no_color': ..., 'out': 'sleep.tfplan'}
+        retcode, stdout, stderr = TerraformCommand.run('plan', options=options, chdir=tmp_sleep_dir)
+        assert retcode == 0, stderr
+        plan, diags = TerraformCommand(tmp_sleep_dir).show_plan_json('sleep.tfplan')
+        summary = TerraformCommand.render_plan_summary(plan)
+        assert summary.startswith('Plan: 2 to add, 0 to change, 0 to destroy.\n\nChanges to Outputs:\n  + ')
+        assert summary in stdout
+
+        TerraformCommand(tmp_sleep_dir).apply('sleep.tfplan', check=True)
+        retcode, stdout, stderr = TerraformCommand.run('plan', options=options, chdir=tmp_sleep_dir)
+        assert retcode == 0, stderr
+        plan, diags = TerraformCommand(tmp_sleep_dir).show_plan_json('sleep.tfplan')
+        summary = TerraformCommand.render_plan_summary(plan)
+        assert summary.startswith('No changes.')
+        assert summary in stdout
+
+    def test_render_plan_summary_invalid(self):
+        assert TerraformCommand.render_plan_summary('not json') == ''
+
+    def test_render_plan_summary_unknown_action(self):
+        assert TerraformCommand.render_plan_summary({'resource_changes': [
+            {'address': 'time_sleep.a', 'mode': 'managed', 'change': {'actions': ['forget']}},
+        ]}) == ''
+        assert TerraformCommand.render_plan_summary({'output_changes': {
+            'id': {'actions': ['forget'], 'before': 'a', 'after': None},
+        }}) == ''
@@ -64,3 +64,53 @@ class TestTerraformCommandState:
         r = cli.state_rm('time_sleep.wait1')
         assert r.retcode == 0, r.error
         assert r.value
+
+    def test_state_provider_versions(self, cli: TerraformCommand):
+        cli.apply()
+        result, diags = cli.state_provider_versions()
+        assert result['Workspace'] == 'default'
+        assert result['TerraformVersion']
+        assert len(result['Providers']) == 1
+        provider = result['Providers'][0]
+        assert provider['Provider'] == 'registry.terraform.io/hashicorp/time'
+        assert provider['Resources'] == ['time_sleep.wait1', 'time_sleep.wait2']
+
+        with open(os.path.join(cli.cwd, '.terraform.lock.hcl')) as f:
+            lock = f.read()
+        assert provider['Version'] and f'"{provider["Version"]}"' in lock
+
+    def test_workspace_state_serial(self, cli: TerraformCommand, tmp_path):
+        cwd = str(tmp_path / 'sleep')
+        shutil.copytree(cli.cwd, cwd, ignore=shutil.ignore_patterns('*.tfstate*', '*.tfplan'))
+        cli = TerraformCommand(cwd)
+        serial, diags = cli.workspace_state_serial()
+        assert serial is None
+
+        cli.apply(check=True)
+        serial, diags = cli.workspace_state_serial()
+        assert serial is not None
+
+        cli.apply(vars={'time1': '2s'}, check=True)
+        new_serial, diags = cli.workspace_state_serial()
+        assert new_serial > serial
+
+    def test_find_removable_providers(self, tmp_path):
+        main_tf = tmp_path / 'main.tf'
+        main_tf.write_text('resource "time_static" "a" {}\n')
+        cli = TerraformCommand(str(tmp_path))
+        cli.init(check=True)
+        cli.apply(check=True)
+
+        providers, diags = cli.find_removable_providers()
+        assert providers == []
+
+        main_tf.write_text('')
+        providers, diags = cli.find_removable_providers()
+        assert len(providers) == 1
+        assert providers[0]['Provider'] == 'registry.terraform.io/hashicorp/time'
+        assert providers[0]['Version']
+        assert providers[0]['Resources'] == ['time_static.a']
+
+        cli.apply(check=True)
+        providers, diags = cli.find_removable_providers()
+        assert providers[0]['Resources'] == []
@@ -1,3 +1,5 @@
+import os
+
 from libterraform import TerraformCommand
 
 
@@ -12,3 +14,24 @@ class TestTerraformCommandValidate:
             'warning_count': 0,
             'diagnostics': []
         }
+
+    def test_validate_config(self, cli: TerraformCommand):
+        result, diags = cli.validate_config()
+        assert result == {'Valid': True, 'ErrorCount': 0, 'WarningCount': 0}
+        assert diags == []
+
+    def test_validate_config_invalid(self, tmp_sleep_dir):
+        cwd = tmp_sleep_dir
+        with open(os.path.join(cwd, 'invalid.tf'), 'w') as f:
+            f.write('resource "time_sleep" "invalid" {\n  unknown_duration = "1s"\n}\n')
+
+        result, diags = TerraformCommand(cwd).validate_config()
+        assert result['Valid'] is False
+        assert result['ErrorCount'] == 1
+        assert diags[0]['summary'] == 'Unsupported argument'
+
+    def test_validate_config_not_installed(self, tmp_sleep_config):
+        result, diags = TerraformCommand(tmp_sleep_config).validate_config()
+        assert result['Valid'] is False
+        assert diags[0]['summary'] == 'Provider not installed'
+        assert 'terraform init' in diags[0]['detail']
@@ -12,3 +12,9 @@ class TestTerraformCommandVersion:
         r = cli.version(json=False)
         assert r.json is False
         assert 'Terraform' in r.value
+
+    def test_get_version(self):
+        version = TerraformCommand.get_version()
+        for key in ('version', 'prerelease', 'platform', 'go_version'):
+            assert key in version
+        assert TerraformCommand().version().value['terraform_version'].startswith(version['version'])
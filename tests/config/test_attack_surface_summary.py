@@ -0,0 +1,15 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_EXPOSED_DIR, TF_SLEEP_DIR
+
+
+class TestTerraformConfigAttackSurfaceSummary:
+    def test_attack_surface_summary(self):
+        resources, diags = TerraformConfig.attack_surface_summary(TF_EXPOSED_DIR)
+        assert [r['Address'] for r in resources] == ['aws_s3_bucket.assets', 'aws_security_group.web']
+        assert resources[0]['Reasons'] == ['bucket ACL public-read grants public access']
+        assert resources[1]['Type'] == 'aws_security_group'
+        assert resources[1]['Reasons'] == ['ingress open to 0.0.0.0/0 on port 443']
+
+    def test_attack_surface_summary_none(self):
+        resources, diags = TerraformConfig.attack_surface_summary(TF_SLEEP_DIR)
+        assert resources == []
@@ -0,0 +1,83 @@
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+import pytest
+
+from libterraform import TerraformConfig
+
+MODULE_VERSIONS = {
+    'acme/net/aws': ['1.0.0', '1.2.0', '2.0.0'],
+    'acme/db/aws': ['2.0.0', '2.1.0'],
+}
+
+
+class RegistryHandler(BaseHTTPRequestHandler):
+    def do_GET(self):
+        prefix, suffix = '/v1/modules/', '/versions'
+        module = self.path[len(prefix):-len(suffix)]
+        if not self.path.startswith(prefix) or not self.path.endswith(suffix) or module not in MODULE_VERSIONS:
+            self.send_error(404)
+            return
+        body = json.dumps({'modules': [{
+            'source': module,
+            'versions': [{'version': v} for v in MODULE_VERSIONS[module]],
+        }]}).encode('utf-8')
+        self.send_response(200)
+        self.send_header('Content-Type', 'application/json')
+        self.send_header('Content-Length', str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+    def log_message(self, format, *args):
+        pass
+
+
+@pytest.fixture
+def registry():
+    server = HTTPServer(('localhost', 0), RegistryHandler)
+    thread = threading.Thread(target=server.serve_forever, daemon=True)
+    thread.start()
+    yield f'localhost:{server.server_port}'
+    server.shutdown()
+    server.server_close()
+
+
+class TestTerraformConfigCheckModuleVersions:
+    def test_check_module_versions(self, tmp_path, registry):
+        cli_config_file = tmp_path / 'terraformrc'
+        cli_config_file.write_text(f'''
+host "{registry}" {{
+  services = {{
+    "modules.v1" = "http://{registry}/v1/modules/"
+  }}
+}}
+''')
+        module_dir = tmp_path / 'module'
+        module_dir.mkdir()
+        (module_dir / 'main.tf').write_text(f'''
+module "net" {{
+  source  = "{registry}/acme/net/aws"
+  version = "~> 1.0"
+}}
+
+module "db" {{
+  source  = "{registry}/acme/db/aws"
+  version = "~> 1.0"
+}}
+
+module "local" {{
+  source = "./local"
+}}
+''')
+
+        modules, diags = TerraformConfig.check_module_versions(str(module_dir), str(cli_config_file))
+        assert not diags
+        assert [m['Name'] for m in modules] == ['db', 'net']
+        db, net = modules
+        assert net['Source'] == f'{registry}/acme/net/aws'
+        assert net['Satisfiable'] is True
+        assert net['Version'] == '1.2.0'
+        assert db['Satisfiable'] is False
+        assert db['Version'] == ''
+        assert db['Error'] == ''
@@ -0,0 +1,15 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_OUTPUTS_DIR
+
+
+class TestTerraformConfigCheckOutputReferences:
+    def test_check_output_references(self):
+        refs, diags = TerraformConfig.check_output_references(TF_OUTPUTS_DIR)
+        assert [(r['Output'], r['Reference'], r['Summary']) for r in refs] == [
+            ('missing', 'var.missing', 'Reference to undeclared input variable'),
+            ('missing', 'local.missing', 'Reference to undeclared local value'),
+            ('removed_id', 'time_sleep.removed', 'Reference to undeclared resource'),
+        ]
+        assert refs[2]['DeclRange']['Start']['Line'] == 19
+        assert len(diags) == 3
+        assert all(d['severity'] == 'error' for d in diags)
@@ -0,0 +1,17 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_SLEEP_DIR, TF_SOURCES_DIR
+
+
+class TestTerraformConfigCheckProviderSources:
+    def test_check_provider_sources(self):
+        problems, diags = TerraformConfig.check_provider_sources(TF_SOURCES_DIR)
+        reasons = {p['Name']: p['Reason'] for p in problems}
+        assert reasons == {
+            'legacy': 'legacy_source',
+            'null': 'implied',
+            'short': 'short_source',
+        }
+
+    def test_check_provider_sources_implied(self):
+        problems, diags = TerraformConfig.check_provider_sources(TF_SLEEP_DIR)
+        assert [p['Name'] for p in problems] == ['time']
@@ -0,0 +1,61 @@
+import json
+
+from libterraform import TerraformConfig
+
+NETWORK_STATE = {
+    'version': 4,
+    'terraform_version': '1.2.2',
+    'serial': 1,
+    'lineage': '5f6a8b1e-3c2d-4e7f-9a0b-1c2d3e4f5a6b',
+    'outputs': {
+        'vpc_id': {'value': 'vpc-123', 'type': 'string'},
+    },
+    'resources': [],
+}
+
+
+class TestTerraformConfigCheckRemoteStateReferences:
+    def test_check_remote_state_references(self, tmp_path):
+        (tmp_path / 'network.tfstate').write_text(json.dumps(NETWORK_STATE))
+        (tmp_path / 'main.tf').write_text('''
+data "terraform_remote_state" "network" {
+  backend = "local"
+  config = {
+    path = "network.tfstate"
+  }
+}
+
+output "vpc_id" {
+  value = data.terraform_remote_state.network.outputs.vpc_id
+}
+
+output "subnet_id" {
+  value = data.terraform_remote_state.network.outputs["subnet_id"]
+}
+''')
+        refs, diags = TerraformConfig.check_remote_state_references(str(tmp_path))
+        assert [(r['DataSource'], r['Output'], r['Exists']) for r in refs] == [
+            ('data.terraform_remote_state.network', 'vpc_id', True),
+            ('data.terraform_remote_state.network', 'subnet_id', False),
+        ]
+        assert refs[1]['DeclRange']['Start']['Line'] == 14
+        assert [d['summary'] for d in diags] == ['Reference to undeclared remote state output']
+
+    def test_check_remote_state_references_unreadable(self, tmp_path):
+        (tmp_path / 'main.tf').write_text('''
+resource "time_static" "created" {}
+
+data "terraform_remote_state" "network" {
+  backend = "local"
+  config = {
+    path = "${time_static.created.id}.tfstate"
+  }
+}
+
+output "vpc_id" {
+  value = data.terraform_remote_state.network.outputs.vpc_id
+}
+''')
+        refs, diags = TerraformConfig.check_remote_state_references(str(tmp_path))
+        assert refs == []
+        assert [d['summary'] for d in diags] == ['Remote state not checked']
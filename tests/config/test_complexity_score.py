@@ -0,0 +1,19 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_COMPLEX_DIR, TF_SLEEP_DIR
+
+
+class TestTerraformConfigComplexityScore:
+    def test_complexity_score(self):
+        complexity, diags = TerraformConfig.complexity_score(TF_COMPLEX_DIR)
+        assert complexity['Resources'] == 2
+        assert complexity['Modules'] == 0
+        assert complexity['DynamicBlocks'] == 2
+        assert complexity['Conditionals'] == 3
+        assert complexity['Interpolations'] == 3
+        assert complexity['Score'] == 2 + 2 * 3 + 3 * 2 + 3
+
+    def test_complexity_score_simple(self):
+        simple, _ = TerraformConfig.complexity_score(TF_SLEEP_DIR)
+        complex_, _ = TerraformConfig.complexity_score(TF_COMPLEX_DIR)
+        assert simple['DynamicBlocks'] == 0
+        assert simple['Score'] < complex_['Score']
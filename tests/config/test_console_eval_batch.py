@@ -0,0 +1,22 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_LOCALS_DIR
+
+
+class TestTerraformConfigConsoleEvalBatch:
+    def test_console_eval_batch(self):
+        results, diags = TerraformConfig.console_eval_batch(
+            TF_LOCALS_DIR,
+            ['local.fqdn', 'upper(var.env)', 'time_sleep.wait[0].id'],
+            vars={'env': 'prod'},
+        )
+        assert [r['Expression'] for r in results] == ['local.fqdn', 'upper(var.env)', 'time_sleep.wait[0].id']
+        assert results[0]['Value'] == {'Value': 'app-prod.example.com', 'Known': True}
+        assert results[0]['Diagnostics'] == []
+        assert results[1]['Value'] == {'Value': 'PROD', 'Known': True}
+        assert results[2]['Value'] is None
+        assert results[2]['Diagnostics'][0]['severity'] == 'error'
+
+    def test_console_eval_batch_without_module(self):
+        results, diags = TerraformConfig.console_eval_batch(None, ['var.a + var.b', 'max(var.a, var.b)'],
+                                                            vars={'a': 1, 'b': 2})
+        assert [r['Value']['Value'] for r in results] == [3, 2]
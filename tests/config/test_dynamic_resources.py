@@ -0,0 +1,16 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_COUNT_DIR, TF_SLEEP_DIR
+
+
+class TestTerraformConfigDynamicResources:
+    def test_dynamic_resources(self):
+        resources, diags = TerraformConfig.dynamic_resources(TF_COUNT_DIR)
+        assert [(r['Address'], r['MetaArgument'], r['Expression']) for r in resources] == [
+            ('time_sleep.extra', 'count', 'var.extra'),
+            ('time_sleep.fixed', 'count', '5'),
+            ('time_sleep.named', 'for_each', 'var.names'),
+        ]
+
+    def test_dynamic_resources_none(self):
+        resources, diags = TerraformConfig.dynamic_resources(TF_SLEEP_DIR)
+        assert resources == []
@@ -0,0 +1,30 @@
+from libterraform import TerraformConfig
+
+
+class TestTerraformConfigEvalExpression:
+    def test_eval_expression(self):
+        value, diags = TerraformConfig.eval_expression('"${var.env}-bucket"', vars={'env': 'prod'})
+        assert diags == []
+        assert value == {'Value': 'prod-bucket', 'Known': True}
+
+    def test_eval_expression_functions(self):
+        value, diags = TerraformConfig.eval_expression('upper(join("-", var.parts))', vars={'parts': ['a', 'b']})
+        assert value['Value'] == 'A-B'
+
+        value, diags = TerraformConfig.eval_expression('{ for k, v in var.tags : k => length(v) }',
+                                                       vars={'tags': {'name': 'web', 'env': 'prod'}})
+        assert value['Value'] == {'name': 3, 'env': 4}
+
+    def test_eval_expression_unresolvable(self):
+        value, diags = TerraformConfig.eval_expression('aws_s3_bucket.logs.id')
+        assert value is None
+        assert diags[0]['severity'] == 'error'
+
+        value, diags = TerraformConfig.eval_expression('var.missing', vars={'env': 'prod'})
+        assert value is None
+        assert diags[0]['severity'] == 'error'
+
+    def test_eval_expression_invalid(self):
+        value, diags = TerraformConfig.eval_expression('"unterminated')
+        assert value is None
+        assert diags[0]['severity'] == 'error'
@@ -0,0 +1,23 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_LOCALS_DIR
+
+
+class TestTerraformConfigEvaluateLocals:
+    def test_evaluate_locals(self):
+        locals_, diags = TerraformConfig.evaluate_locals(TF_LOCALS_DIR)
+        assert diags == []
+        assert locals_['prefix'] == {'Value': 'app', 'Known': True}
+        assert locals_['name'] == {'Value': 'app-dev', 'Known': True}
+        assert locals_['fqdn'] == {'Value': 'app-dev.example.com', 'Known': True}
+        assert locals_['zone_names']['Value'] == ['app-dev-a', 'app-dev-b']
+        assert locals_['replicas']['Value'] == 1
+        assert locals_['sleep_id'] == {'Value': None, 'Known': False}
+
+    def test_evaluate_locals_with_vars(self):
+        locals_, diags = TerraformConfig.evaluate_locals(TF_LOCALS_DIR, vars={'env': 'prod', 'zones': ['a', 'b', 'c']})
+        assert locals_['fqdn']['Value'] == 'app-prod.example.com'
+        assert locals_['replicas']['Value'] == 6
+
+    def test_total_instance_count_with_locals(self):
+        count, diags = TerraformConfig.total_instance_count(TF_LOCALS_DIR, vars={'env': 'prod'})
+        assert count['Count'] == 4
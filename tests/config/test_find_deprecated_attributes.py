@@ -0,0 +1,48 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_DEPRECATED_DIR
+
+AWS_SCHEMAS = {
+    'format_version': '1.0',
+    'provider_schemas': {
+        'registry.terraform.io/hashicorp/aws': {
+            'resource_schemas': {
+                'aws_instance': {
+                    'version': 1,
+                    'block': {
+                        'attributes': {
+                            'ami': {'type': 'string', 'optional': True},
+                            'instance_type': {'type': 'string', 'optional': True},
+                            'cpu_core_count': {'type': 'number', 'optional': True, 'deprecated': True},
+                        },
+                        'block_types': {
+                            'ebs_block_device': {
+                                'nesting_mode': 'set',
+                                'block': {
+                                    'attributes': {
+                                        'device_name': {'type': 'string', 'required': True},
+                                        'iops': {'type': 'number', 'optional': True, 'deprecated': True},
+                                    },
+                                },
+                            },
+                        },
+                    },
+                },
+            },
+        },
+    },
+}
+
+
+class TestTerraformConfigFindDeprecatedAttributes:
+    def test_find_deprecated_attributes(self):
+        resources, diags = TerraformConfig.find_deprecated_attributes(TF_DEPRECATED_DIR, schemas=AWS_SCHEMAS)
+        assert diags == []
+        assert len(resources) == 1
+        assert resources[0]['Address'] == 'aws_instance.web'
+        assert resources[0]['Provider'] == 'registry.terraform.io/hashicorp/aws'
+        assert resources[0]['Attributes'] == ['cpu_core_count', 'ebs_block_device.iops']
+
+    def test_find_deprecated_attributes_without_schema(self):
+        resources, diags = TerraformConfig.find_deprecated_attributes(TF_DEPRECATED_DIR, schemas={'provider_schemas': {}})
+        assert resources == []
+        assert diags[0]['summary'] == 'Missing provider schema'
@@ -0,0 +1,34 @@
+from libterraform import TerraformConfig
+
+LOCK_FILE = '''provider "registry.terraform.io/hashicorp/random" {
+  version = "3.1.0"
+}
+'''
+
+
+class TestTerraformConfigFindUnlockedProviders:
+    def test_find_unlocked_providers(self, tmp_path):
+        (tmp_path / 'main.tf').write_text('''
+resource "random_id" "id" {
+  byte_length = 4
+}
+
+resource "time_static" "b" {}
+
+resource "time_static" "a" {}
+''')
+        (tmp_path / '.terraform.lock.hcl').write_text(LOCK_FILE)
+
+        providers, diags = TerraformConfig.find_unlocked_providers(str(tmp_path))
+        assert not diags
+        assert providers == [{
+            'Provider': 'registry.terraform.io/hashicorp/time',
+            'Resources': ['time_static.a', 'time_static.b'],
+        }]
+
+    def test_find_unlocked_providers_locked(self, tmp_path):
+        (tmp_path / 'main.tf').write_text('resource "random_id" "id" {\n  byte_length = 4\n}\n')
+        (tmp_path / '.terraform.lock.hcl').write_text(LOCK_FILE)
+
+        providers, diags = TerraformConfig.find_unlocked_providers(str(tmp_path))
+        assert providers == []
@@ -0,0 +1,23 @@
+from libterraform import TerraformConfig
+from tests.config.test_fmt_patch import FORMATTED_TF, FORMATTED_TFVARS, MISFORMATTED_TF, MISFORMATTED_TFVARS
+
+
+class TestTerraformConfigFmtModule:
+    def test_fmt_module(self):
+        files, diags = TerraformConfig.fmt_module({
+            'main.tf': MISFORMATTED_TF,
+            'dev.tfvars': MISFORMATTED_TFVARS,
+        })
+        assert not diags
+        assert files == {
+            'main.tf': FORMATTED_TF,
+            'dev.tfvars': FORMATTED_TFVARS,
+        }
+
+    def test_fmt_module_invalid(self):
+        files, diags = TerraformConfig.fmt_module({
+            'main.tf': FORMATTED_TF,
+            'broken.tf': 'resource "time_sleep" "wait" {\n',
+        })
+        assert files == {'main.tf': FORMATTED_TF}
+        assert [d['summary'] for d in diags] == ['Failed to format broken.tf']
@@ -0,0 +1,56 @@
+import subprocess
+
+from libterraform import TerraformConfig
+
+MISFORMATTED_TF = '''resource "time_sleep" "wait" {
+create_duration = "1s"
+  triggers = {
+    a="1"
+    bb = "2"
+  }
+}
+'''
+
+FORMATTED_TF = '''resource "time_sleep" "wait" {
+  create_duration = "1s"
+  triggers = {
+    a  = "1"
+    bb = "2"
+  }
+}
+'''
+
+MISFORMATTED_TFVARS = 'time1="1s"\ntime2 =  "2s"\n'
+
+FORMATTED_TFVARS = 'time1 = "1s"\ntime2 = "2s"\n'
+
+
+class TestTerraformConfigFmtPatch:
+    def test_fmt_patch(self, tmp_path):
+        (tmp_path / 'main.tf').write_text(MISFORMATTED_TF)
+        (tmp_path / 'ok.tf').write_text(FORMATTED_TF)
+        (tmp_path / 'env').mkdir()
+        (tmp_path / 'env' / 'dev.tfvars').write_text(MISFORMATTED_TFVARS)
+
+        patch, diags = TerraformConfig.fmt_patch(str(tmp_path))
+        assert not diags
+        assert 'diff --git a/env/dev.tfvars b/env/dev.tfvars' in patch
+        assert 'diff --git a/main.tf b/main.tf' in patch
+        assert 'ok.tf' not in patch
+
+        (tmp_path / 'fmt.patch').write_text(patch)
+        subprocess.run(['git', 'apply', 'fmt.patch'], cwd=str(tmp_path), check=True)
+        assert (tmp_path / 'main.tf').read_text() == FORMATTED_TF
+        assert (tmp_path / 'env' / 'dev.tfvars').read_text() == FORMATTED_TFVARS
+
+        patch, diags = TerraformConfig.fmt_patch(str(tmp_path))
+        assert patch == ''
+
+    def test_fmt_patch_invalid(self, tmp_path):
+        (tmp_path / 'main.tf').write_text(MISFORMATTED_TF)
+        (tmp_path / 'broken.tf').write_text('resource "time_sleep" "wait" {\n')
+
+        patch, diags = TerraformConfig.fmt_patch(str(tmp_path))
+        assert 'diff --git a/main.tf b/main.tf' in patch
+        assert [d['summary'] for d in diags] == ['Failed to format broken.tf']
+        assert diags[0]['detail'].startswith('Unclosed configuration block on line 1')
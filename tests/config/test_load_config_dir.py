@@ -1,8 +1,12 @@
+import json
+import os
+from ctypes import c_char_p
+
 import pytest
 
-from libterraform import TerraformConfig
+from libterraform import TerraformConfig, _lib_tf, _Result, _decode_result
 from libterraform.exceptions import LibTerraformError
-from tests.consts import TF_SLEEP_DIR
+from tests.consts import TF_DUPES_DIR, TF_SLEEP_DIR
 
 
 class TestTerraformConfig:
@@ -11,6 +15,135 @@ class TestTerraformConfig:
         assert 'time_sleep.wait1' in mod['ManagedResources']
         assert 'time_sleep.wait2' in mod['ManagedResources']
 
+    def test_load_config_dir_export_without_options(self):
+        # The export keeps its original signature for existing callers.
+        load_config_dir = _lib_tf.ConfigLoadConfigDir
+        load_config_dir.argtypes = [c_char_p]
+        load_config_dir.restype = _Result
+        r_mod, r_diags, err = _decode_result(load_config_dir(TF_SLEEP_DIR.encode('utf-8')))
+        assert not err
+        assert 'time_sleep.wait1' in json.loads(r_mod)['ManagedResources']
+
     def test_load_config_dir_no_exits(self):
         with pytest.raises(LibTerraformError):
             TerraformConfig.load_config_dir('not-exits')
+
+    def test_load_config_dir_resource_details(self):
+        mod, diags = TerraformConfig.load_config_dir(TF_SLEEP_DIR)
+        wait1 = mod['ResourceDetails']['time_sleep.wait1']
+        assert wait1['Mode'] == 'managed'
+        assert wait1['Provider'] == 'registry.terraform.io/hashicorp/time'
+        assert wait1['ProviderConfig'] == 'time'
+        assert wait1['HasCount'] is False
+        assert wait1['HasForEach'] is False
+        assert wait1['DeclRange']['Filename'].endswith('main.tf')
+        assert wait1['DeclRange']['Start']['Line'] == 11
+
+    def test_load_config_dir_module_sources(self, tmp_path):
+        (tmp_path / 'main.tf').write_text('''
+module "local" {
+  source = "./modules/local"
+}
+
+module "registry" {
+  source  = "hashicorp/consul/aws"
+  version = "~> 0.1"
+}
+
+module "git" {
+  source = "github.com/hashicorp/example"
+}
+
+module "http" {
+  source = "https://example.com/module.zip"
+}
+
+module "invalid" {
+  source = "modules/invalid"
+}
+''')
+        mod, diags = TerraformConfig.load_config_dir(str(tmp_path))
+        sources = mod['ModuleSources']
+        assert {name: source['Type'] for name, source in sources.items()} == {
+            'local': 'local',
+            'registry': 'registry',
+            'git': 'git',
+            'http': 'http',
+            'invalid': 'invalid',
+        }
+        assert sources['registry']['Raw'] == 'hashicorp/consul/aws'
+        assert sources['registry']['Address'] == 'registry.terraform.io/hashicorp/consul/aws'
+        assert sources['registry']['Version'] == '~> 0.1'
+        assert sources['git']['Address'] == 'git::https://github.com/hashicorp/example.git'
+        assert sources['invalid']['Address'] == ''
+        assert [d['summary'] for d in diags] == ['Invalid module source address']
+
+    def test_load_config_dir_import_and_check(self, tmp_path):
+        (tmp_path / 'main.tf').write_text('''
+resource "time_static" "imported" {}
+
+import {
+  to = time_static.imported
+  id = "2024-01-01T00:00:00Z"
+}
+
+check "health" {
+  data "http" "health" {
+    url = "https://example.com/health"
+  }
+
+  assert {
+    condition     = data.http.health.status_code == 200
+    error_message = "unhealthy"
+  }
+}
+''')
+        mod, diags = TerraformConfig.load_config_dir(str(tmp_path))
+        [imp] = mod['Import']
+        assert imp['To'] == 'time_static.imported'
+        assert imp['ID'] == '2024-01-01T00:00:00Z'
+        assert 'Provider' not in imp
+        assert imp['DeclRange']['Start']['Line'] == 4
+        [check] = mod['Checks']
+        assert check['Name'] == 'health'
+        assert check['DataResources'] == ['data.http.health']
+        [assertion] = check['Assertions']
+        assert assertion['Condition'] == 'data.http.health.status_code == 200'
+        assert assertion['ErrorMessage'] == 'unhealthy'
+        assert check['DeclRange']['Start']['Line'] == 9
+
+    def test_load_config_dir_import_and_check_json(self, tmp_path):
+        (tmp_path / 'main.tf.json').write_text(json.dumps({
+            'resource': {'time_static': {'imported': {}}},
+            'import': [{'to': 'time_static.imported', 'id': '2024-01-01T00:00:00Z'}],
+            'check': {'health': {'assert': [{'condition': '${true}', 'error_message': 'unhealthy'}]}},
+        }, indent=2))
+        mod, diags = TerraformConfig.load_config_dir(str(tmp_path))
+        [imp] = mod['Import']
+        assert imp['To'] == 'time_static.imported'
+        assert imp['ID'] == '2024-01-01T00:00:00Z'
+        assert imp['DeclRange']['Filename'].endswith('main.tf.json')
+        [check] = mod['Checks']
+        assert check['Name'] == 'health'
+        assert check['DataResources'] == []
+        [assertion] = check['Assertions']
+        assert assertion['ErrorMessage'] == 'unhealthy'
+
+    def test_load_config_dir_without_import_and_check(self):
+        mod, diags = TerraformConfig.load_config_dir(TF_SLEEP_DIR)
+        assert 'Import' not in mod
+        assert 'Checks' not in mod
+
+    def test_load_config_dir_diagnostics(self):
+        mod, diags = TerraformConfig.load_config_dir(os.path.join(TF_DUPES_DIR, 'broken'))
+        assert len(diags) == 1
+        diag = diags[0]
+        assert diag['severity'] == 'error'
+        assert diag['summary'] == 'Missing name for resource'
+        assert diag['filename'].endswith('main.tf')
+        assert diag['start_line'] == 1
+        assert diag['end_line'] == 1
+        assert diag['start_column'] < diag['end_column']
+        assert diag['subject']['filename'] == diag['filename']
+        assert diag['subject']['start']['line'] == 1
+        assert diag['subject']['start']['column'] == diag['start_column']
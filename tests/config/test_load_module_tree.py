@@ -0,0 +1,44 @@
+import pytest
+
+from libterraform import TerraformConfig
+from libterraform.exceptions import LibTerraformError
+from tests.consts import TF_DUPES_DIR, TF_TREE_DIR
+
+
+class TestTerraformConfigLoadModuleTree:
+    def test_load_module_tree(self):
+        tree, diags = TerraformConfig.load_module_tree(TF_TREE_DIR)
+        assert tree['Path'] == ''
+        assert set(tree['Children']) == {'child', 'remote'}
+
+        child = tree['Children']['child']
+        assert child['Path'] == 'module.child'
+        assert child['Source'] == './modules/child'
+        assert child['Unresolved'] is False
+        assert 'time_sleep.child' in child['Module']['ManagedResources']
+
+        grandchild = child['Children']['grandchild']
+        assert grandchild['Path'] == 'module.child.module.grandchild'
+        assert 'time_sleep.grandchild' in grandchild['Module']['ManagedResources']
+
+    def test_load_module_tree_unresolved(self):
+        tree, diags = TerraformConfig.load_module_tree(TF_TREE_DIR)
+        remote = tree['Children']['remote']
+        assert remote['Unresolved'] is True
+        assert remote['Source'] == 'hashicorp/consul/aws'
+        assert remote['Module'] is None
+
+    def test_load_module_tree_no_exists(self):
+        with pytest.raises(LibTerraformError):
+            TerraformConfig.load_module_tree('not-exists')
+
+    def test_load_module_tree_dedupe(self):
+        tree, diags = TerraformConfig.load_module_tree(TF_DUPES_DIR)
+        errors = [d for d in diags if d['severity'] == 'error']
+        assert len(errors) == 2
+        assert errors[0]['summary'] == errors[1]['summary']
+
+        tree, diags = TerraformConfig.load_module_tree(TF_DUPES_DIR, dedupe=True)
+        errors = [d for d in diags if d['severity'] == 'error']
+        assert len(errors) == 1
+        assert errors[0]['module'] == 'module.a'
@@ -0,0 +1,26 @@
+import os
+
+from libterraform import TerraformConfig
+from tests.consts import TF_MOVED_DIR
+
+
+class TestTerraformConfigMovedStatements:
+    def test_moved_statements(self):
+        statements, diags = TerraformConfig.moved_statements(TF_MOVED_DIR)
+        assert [(s['From'], s['To']) for s in statements] == [
+            ('time_static.original', 'time_static.renamed'),
+            ('time_static.old', 'time_static.original'),
+            ('time_static.kept', 'time_static.other'),
+        ]
+        assert statements[0]['Module'] == ''
+        assert statements[0]['DeclRange']['Start']['Line'] == 5
+        assert statements[0]['Problems'] == []
+        assert statements[1]['Problems'] == ['Chained move statements']
+        assert statements[2]['Problems'] == ['Moved object still exists']
+        assert {d['summary'] for d in diags} == {'Chained move statements', 'Moved object still exists'}
+
+    def test_moved_statements_cycle(self):
+        statements, diags = TerraformConfig.moved_statements(os.path.join(TF_MOVED_DIR, 'cycle'))
+        assert len(statements) == 2
+        for statement in statements:
+            assert 'Cyclic dependency in move statements' in statement['Problems']
@@ -0,0 +1,73 @@
+from libterraform import TerraformConfig
+
+REQUIRED_PROVIDERS = '''
+terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+'''
+
+
+class TestTerraformConfigProviderInheritance:
+    def test_provider_inheritance(self, tmp_path):
+        (tmp_path / 'main.tf').write_text(REQUIRED_PROVIDERS + '''
+provider "aws" {
+  region = "us-east-1"
+}
+
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+
+module "net" {
+  source = "./net"
+  providers = {
+    aws = aws.west
+  }
+}
+
+module "app" {
+  source = "./app"
+}
+''')
+        (tmp_path / 'net' / 'subnet').mkdir(parents=True)
+        (tmp_path / 'net' / 'main.tf').write_text(REQUIRED_PROVIDERS + '''
+resource "aws_vpc" "main" {}
+
+module "subnet" {
+  source = "./subnet"
+}
+''')
+        (tmp_path / 'net' / 'subnet' / 'main.tf').write_text(REQUIRED_PROVIDERS + 'resource "aws_subnet" "main" {}\n')
+        (tmp_path / 'app').mkdir()
+        (tmp_path / 'app' / 'main.tf').write_text(REQUIRED_PROVIDERS + 'resource "aws_instance" "web" {}\n')
+
+        providers, diags = TerraformConfig.provider_inheritance(str(tmp_path))
+        assert not [d for d in diags if d['severity'] == 'error']
+        assert providers == [
+            {
+                'Module': 'module.app',
+                'InChild': 'aws',
+                'InParent': 'aws',
+                'Implicit': True,
+                'Resolved': 'provider["registry.terraform.io/hashicorp/aws"]',
+            },
+            {
+                'Module': 'module.net',
+                'InChild': 'aws',
+                'InParent': 'aws.west',
+                'Implicit': False,
+                'Resolved': 'provider["registry.terraform.io/hashicorp/aws"].west',
+            },
+            {
+                'Module': 'module.net.module.subnet',
+                'InChild': 'aws',
+                'InParent': 'aws',
+                'Implicit': True,
+                'Resolved': 'provider["registry.terraform.io/hashicorp/aws"].west',
+            },
+        ]
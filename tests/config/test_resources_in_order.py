@@ -0,0 +1,13 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_ORDER_DIR
+
+
+class TestTerraformConfigResourcesInOrder:
+    def test_resources_in_order(self):
+        resources, diags = TerraformConfig.resources_in_order(TF_ORDER_DIR)
+        assert [r['Address'] for r in resources] == [
+            'time_sleep.zulu',
+            'time_static.mike',
+            'time_sleep.alpha',
+            'time_offset.bravo',
+        ]
@@ -0,0 +1,14 @@
+from libterraform import TerraformConfig
+from tests.consts import TF_SECRETS_DIR
+
+
+class TestTerraformConfigSecretReferences:
+    def test_secret_references(self):
+        secrets, diags = TerraformConfig.secret_references(TF_SECRETS_DIR)
+        assert [s['Address'] for s in secrets] == [
+            'data.aws_secretsmanager_secret_version.api',
+            'data.vault_generic_secret.db',
+        ]
+        assert secrets[0]['Keys'] == {'secret_id': '"${var.env}/api-key"'}
+        assert secrets[1]['Type'] == 'vault_generic_secret'
+        assert secrets[1]['Keys'] == {'path': 'secret/db'}
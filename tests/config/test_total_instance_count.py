@@ -0,0 +1,28 @@
+import pytest
+
+from libterraform import TerraformConfig
+from libterraform.exceptions import LibTerraformError
+from tests.consts import TF_COUNT_DIR, TF_SLEEP_DIR
+
+
+class TestTerraformConfigTotalInstanceCount:
+    def test_total_instance_count(self):
+        count, diags = TerraformConfig.total_instance_count(TF_COUNT_DIR)
+        assert count['Count'] == 5
+        assert count['Unknown'] == []
+
+    def test_total_instance_count_with_vars(self):
+        count, diags = TerraformConfig.total_instance_count(TF_COUNT_DIR, {'extra': 2})
+        assert count['Count'] == 7
+
+    def test_total_instance_count_without_count(self):
+        count, diags = TerraformConfig.total_instance_count(TF_SLEEP_DIR)
+        assert count['Count'] == 2
+
+    def test_total_instance_count_no_exists(self):
+        with pytest.raises(LibTerraformError):
+            TerraformConfig.total_instance_count('not-exists')
+
+    def test_total_instance_count_with_for_each(self):
+        count, diags = TerraformConfig.total_instance_count(TF_COUNT_DIR, {'names': ['a', 'b']})
+        assert count['Count'] == 7
@@ -0,0 +1,28 @@
+from libterraform import TerraformConfig
+
+
+class TestTerraformConfigUsedProviderFunctions:
+    def test_used_provider_functions(self, tmp_path):
+        (tmp_path / 'main.tf').write_text('''
+output "region" {
+  value = provider::aws::arn_parse("arn:aws:iam::123456789012:user/example").region
+}
+
+output "upper" {
+  value = upper("provider::aws::ignored()")
+}
+''')
+        calls, diags = TerraformConfig.used_provider_functions(str(tmp_path))
+        assert not diags
+        assert len(calls) == 1
+        call = calls[0]
+        assert call['Name'] == 'provider::aws::arn_parse'
+        assert call['Provider'] == 'aws'
+        assert call['Function'] == 'arn_parse'
+        assert call['DeclRange']['Start']['Line'] == 3
+
+    def test_used_provider_functions_none(self, tmp_path):
+        (tmp_path / 'main.tf').write_text('output "upper" {\n  value = upper("a")\n}\n')
+        calls, diags = TerraformConfig.used_provider_functions(str(tmp_path))
+        assert calls == []
+        assert not diags
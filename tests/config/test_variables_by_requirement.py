@@ -0,0 +1,18 @@
+from libterraform import TerraformConfig
+
+
+class TestTerraformConfigVariablesByRequirement:
+    def test_variables_by_requirement(self, tmp_path):
+        (tmp_path / 'variables.tf').write_text('''
+variable "name" {
+  type = string
+}
+
+variable "size" {
+  type    = number
+  default = 1
+}
+''')
+        groups, diags = TerraformConfig.variables_by_requirement(str(tmp_path))
+        assert not diags
+        assert groups == {'Required': ['name'], 'Optional': ['size']}
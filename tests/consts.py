@@ -3,3 +3,20 @@ import os
 ROOT = os.path.dirname(__file__)
 TF_DIR = os.path.join(ROOT, 'tf')
 TF_SLEEP_DIR = os.path.join(TF_DIR, 'sleep')
+TF_STEPS_DIR = os.path.join(TF_DIR, 'steps')
+TF_COUNT_DIR = os.path.join(TF_DIR, 'count')
+TF_ORDER_DIR = os.path.join(TF_DIR, 'order')
+TF_SOURCES_DIR = os.path.join(TF_DIR, 'sources')
+TF_TREE_DIR = os.path.join(TF_DIR, 'tree')
+TF_SECRETS_DIR = os.path.join(TF_DIR, 'secrets')
+TF_DUPES_DIR = os.path.join(TF_DIR, 'dupes')
+TF_COMPLEX_DIR = os.path.join(TF_DIR, 'complex')
+TF_REPLACE_DIR = os.path.join(TF_DIR, 'replace')
+TF_LOCALS_DIR = os.path.join(TF_DIR, 'locals')
+TF_DEPRECATED_DIR = os.path.join(TF_DIR, 'deprecated')
+TF_IDEMPOTENT_DIR = os.path.join(TF_DIR, 'idempotent')
+TF_EXPOSED_DIR = os.path.join(TF_DIR, 'exposed')
+TF_MOVED_DIR = os.path.join(TF_DIR, 'moved')
+TF_OUTPUTS_DIR = os.path.join(TF_DIR, 'outputs')
+TF_BLAST_DIR = os.path.join(TF_DIR, 'blast')
+TF_CHAIN_DIR = os.path.join(TF_DIR, 'chain')
@@ -0,0 +1,10 @@
+from libterraform import _lib_tf, _free
+
+
+class TestFree:
+    def test_free_nil(self):
+        _free(None)
+        _free(None)
+
+    def test_free_deprecated_nil(self):
+        _lib_tf.Free(None)
@@ -0,0 +1,24 @@
+variable "key" {
+  type    = string
+  default = "a"
+}
+
+resource "time_static" "base" {
+  triggers = {
+    key = var.key
+  }
+}
+
+resource "time_static" "dependent" {
+  triggers = {
+    base = time_static.base.rfc3339
+  }
+}
+
+resource "time_static" "indirect" {
+  triggers = {
+    dependent = time_static.dependent.rfc3339
+  }
+}
+
+resource "time_static" "unrelated" {}
@@ -0,0 +1,7 @@
+resource "time_static" "app" {
+  triggers = {
+    network = time_static.network.rfc3339
+  }
+}
+
+resource "time_static" "network" {}
@@ -0,0 +1,44 @@
+variable "env" {
+  type    = string
+  default = "dev"
+}
+
+variable "rules" {
+  type = list(object({
+    port = number
+    cidr = string
+  }))
+  default = []
+}
+
+resource "aws_security_group" "web" {
+  name = "web-${var.env}"
+
+  dynamic "ingress" {
+    for_each = var.rules
+    content {
+      from_port   = ingress.value.port
+      to_port     = ingress.value.port
+      protocol    = "tcp"
+      cidr_blocks = [ingress.value.cidr]
+    }
+  }
+
+  dynamic "egress" {
+    for_each = var.env == "prod" ? [] : [0]
+    content {
+      from_port   = 0
+      to_port     = 0
+      protocol    = "-1"
+      cidr_blocks = ["0.0.0.0/0"]
+    }
+  }
+}
+
+resource "aws_instance" "web" {
+  count         = var.env == "prod" ? 3 : 1
+  instance_type = var.env == "prod" ? "m5.large" : "t3.micro"
+  tags = {
+    Name = "${var.env}-web-${count.index}"
+  }
+}
@@ -0,0 +1,27 @@
+variable "extra" {
+  type    = number
+  default = 0
+}
+
+resource "time_sleep" "fixed" {
+  count = 5
+
+  create_duration = "1s"
+}
+
+resource "time_sleep" "extra" {
+  count = var.extra
+
+  create_duration = "1s"
+}
+
+variable "names" {
+  type    = set(string)
+  default = []
+}
+
+resource "time_sleep" "named" {
+  for_each = var.names
+
+  create_duration = "1s"
+}
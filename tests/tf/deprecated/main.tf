@@ -0,0 +1,15 @@
+resource "aws_instance" "web" {
+  ami            = "ami-12345678"
+  instance_type  = "t3.micro"
+  cpu_core_count = 2
+
+  ebs_block_device {
+    device_name = "/dev/sdb"
+    iops        = 3000
+  }
+}
+
+resource "aws_instance" "db" {
+  ami           = "ami-12345678"
+  instance_type = "t3.micro"
+}
@@ -0,0 +1,3 @@
+resource "time_sleep" {
+  create_duration = "1s"
+}
@@ -0,0 +1,7 @@
+module "a" {
+  source = "./broken"
+}
+
+module "b" {
+  source = "./broken"
+}
@@ -0,0 +1,43 @@
+variable "admin_cidr" {
+  type    = string
+  default = "10.0.0.0/8"
+}
+
+resource "aws_security_group" "web" {
+  name = "web"
+
+  ingress {
+    from_port   = 443
+    to_port     = 443
+    protocol    = "tcp"
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+
+  ingress {
+    from_port   = 22
+    to_port     = 22
+    protocol    = "tcp"
+    cidr_blocks = [var.admin_cidr]
+  }
+}
+
+resource "aws_security_group" "internal" {
+  name = "internal"
+
+  ingress {
+    from_port   = 0
+    to_port     = 65535
+    protocol    = "tcp"
+    cidr_blocks = ["10.0.0.0/8"]
+  }
+}
+
+resource "aws_s3_bucket" "assets" {
+  bucket = "assets"
+  acl    = "public-read"
+}
+
+resource "aws_db_instance" "db" {
+  instance_class      = "db.t3.micro"
+  publicly_accessible = false
+}
@@ -0,0 +1,14 @@
+variable "drift" {
+  type    = bool
+  default = false
+}
+
+resource "time_static" "stable" {
+}
+
+resource "time_static" "drifting" {
+  triggers = {
+    # A new timestamp on every plan makes the resource never converge.
+    now = var.drift ? timestamp() : "fixed"
+  }
+}
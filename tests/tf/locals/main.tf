@@ -0,0 +1,23 @@
+variable "env" {
+  type    = string
+  default = "dev"
+}
+
+variable "zones" {
+  type    = list(string)
+  default = ["a", "b"]
+}
+
+locals {
+  name       = "${local.prefix}-${var.env}"
+  prefix     = "app"
+  fqdn       = "${local.name}.example.com"
+  zone_names = [for z in var.zones : "${local.name}-${z}"]
+  replicas   = var.env == "prod" ? length(local.zone_names) * 2 : 1
+  sleep_id   = time_sleep.wait.id
+}
+
+resource "time_sleep" "wait" {
+  count           = local.replicas
+  create_duration = "1s"
+}
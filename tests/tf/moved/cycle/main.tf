@@ -0,0 +1,9 @@
+moved {
+  from = time_static.x
+  to   = time_static.y
+}
+
+moved {
+  from = time_static.y
+  to   = time_static.x
+}
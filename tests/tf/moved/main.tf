@@ -0,0 +1,18 @@
+resource "time_static" "renamed" {}
+
+resource "time_static" "kept" {}
+
+moved {
+  from = time_static.original
+  to   = time_static.renamed
+}
+
+moved {
+  from = time_static.old
+  to   = time_static.original
+}
+
+moved {
+  from = time_static.kept
+  to   = time_static.other
+}
@@ -0,0 +1,10 @@
+resource "time_sleep" "zulu" {
+  create_duration = "1s"
+}
+
+resource "time_static" "mike" {
+}
+
+resource "time_sleep" "alpha" {
+  create_duration = "1s"
+}
@@ -0,0 +1,3 @@
+resource "time_offset" "bravo" {
+  offset_days = 1
+}
@@ -0,0 +1,24 @@
+variable "name" {
+  type    = string
+  default = "wait"
+}
+
+resource "time_sleep" "wait" {
+  create_duration = "1s"
+}
+
+output "wait_id" {
+  value = time_sleep.wait.id
+}
+
+output "name" {
+  value = var.name
+}
+
+output "removed_id" {
+  value = time_sleep.removed.id
+}
+
+output "missing" {
+  value = "${var.missing}-${local.missing}"
+}
@@ -0,0 +1,20 @@
+variable "key" {
+  type    = string
+  default = "a"
+}
+
+resource "time_static" "cbd" {
+  triggers = {
+    key = var.key
+  }
+
+  lifecycle {
+    create_before_destroy = true
+  }
+}
+
+resource "time_static" "dbc" {
+  triggers = {
+    key = var.key
+  }
+}
@@ -0,0 +1,16 @@
+variable "env" {
+  type    = string
+  default = "prod"
+}
+
+data "vault_generic_secret" "db" {
+  path = "secret/db"
+}
+
+data "aws_secretsmanager_secret_version" "api" {
+  secret_id = "${var.env}/api-key"
+}
+
+data "aws_ami" "other" {
+  most_recent = true
+}
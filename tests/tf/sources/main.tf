@@ -0,0 +1,16 @@
+terraform {
+  required_providers {
+    time = {
+      source = "hashicorp/time"
+    }
+    legacy = {
+      source = "-/legacy"
+    }
+    short = {
+      source = "short"
+    }
+  }
+}
+
+resource "null_resource" "implied" {
+}
@@ -0,0 +1,5 @@
+resource "time_sleep" "step" {
+  count = 3
+
+  create_duration = "1s"
+}
@@ -0,0 +1,8 @@
+module "child" {
+  source = "./modules/child"
+}
+
+module "remote" {
+  source  = "hashicorp/consul/aws"
+  version = "0.11.0"
+}
@@ -0,0 +1,7 @@
+resource "time_sleep" "child" {
+  create_duration = "1s"
+}
+
+module "grandchild" {
+  source = "../grandchild"
+}
@@ -0,0 +1,3 @@
+resource "time_sleep" "grandchild" {
+  create_duration = "1s"
+}